@@ -3,7 +3,6 @@ package main
 import (
 	"encoding/base64"
 	"fmt"
-	"regexp"
 	"strings"
 
 	gc "github.com/opensourceways/robot-github-lib/client"
@@ -24,105 +23,62 @@ const (
 	squashCommand       = "/squash"
 	removeLabel         = "openeuler-cla/yes"
 	ackLabel            = "Acked"
-	msgNotSetReviewer   = "**@%s** Thank you for submitting a PullRequest. It is detected that you have not set a reviewer, please set a one."
+	msgNotSetReviewer   = "**@%s** Thank you for submitting a PullRequest. It is detected that you have not set a reviewer, please set a one, or comment /assign @user to set it."
 	sourceBranchChanged = "synchronize"
 	open                = "open"
 	updateLabel         = "labeled"
+	statusSuccess       = "success"
 )
 
 var (
-	regAck     = regexp.MustCompile(`(?mi)^/ack\s*$`)
-	ackCommand = regexp.MustCompile(`(?mi)^/ack\s*$`)
+	cmdAck          = newCommandMatcher("/ack")
+	cmdRemoveCLA    = newCommandMatcher(removeClaCommand)
+	cmdRebase       = newCommandMatcher(rebaseCommand)
+	cmdRemoveRebase = newCommandMatcher(removeRebase)
+	cmdSquash       = newCommandMatcher(squashCommand)
+	cmdRemoveSquash = newCommandMatcher(removeSquash)
 )
 
-func (bot *robot) removeInvalidCLA(e *sdk.IssueCommentEvent, cfg *botConfig, log *logrus.Entry) error {
-	if !e.GetIssue().IsPullRequest() ||
-		e.GetIssue().GetState() != open ||
-		!gc.IsCommentCreated(e) ||
-		e.GetComment().GetBody() != removeClaCommand {
+func (bot *robot) removeInvalidCLA(ev *commandEvent, cmd command, cfg *botConfig, log *logrus.Entry) error {
+	if !cmdRemoveCLA.match(cmd) {
 		return nil
 	}
 
-	org, repo := gc.GetOrgRepo(e.GetRepo())
-	number := e.GetIssue().GetNumber()
-	commenter := e.GetComment().GetUser().GetLogin()
-
-	hasPermission, err := bot.hasPermission(org, repo, commenter, false, e, cfg, log)
-	if err != nil {
-		return err
-	}
-
-	if !hasPermission {
-		return nil
-	}
-
-	return bot.cli.RemovePRLabel(gc.PRInfo{Org: org, Repo: repo, Number: number}, removeLabel)
+	return bot.cli.RemovePRLabel(ev.pr, removeLabel)
 }
 
-func (bot *robot) handleRebase(e *sdk.IssueCommentEvent, cfg *botConfig, log *logrus.Entry) error {
-	if !e.GetIssue().IsPullRequest() ||
-		e.GetIssue().GetState() != open ||
-		!gc.IsCommentCreated(e) ||
-		e.GetComment().GetBody() != rebaseCommand {
-		return nil
-	}
-
-	org, repo := gc.GetOrgRepo(e.GetRepo())
-	number := e.GetIssue().GetNumber()
-	commenter := e.GetComment().GetUser().GetLogin()
-
-	hasPermission, err := bot.hasPermission(org, repo, commenter, false, e, cfg, log)
-	if err != nil {
-		return err
+func (bot *robot) handleRebase(ev *commandEvent, cmd command, cfg *botConfig, log *logrus.Entry) error {
+	if cmdRemoveRebase.match(cmd) {
+		return bot.removeRebase(ev, cfg, log)
 	}
 
-	if !hasPermission {
+	if !cmdRebase.match(cmd) {
 		return nil
 	}
 
-	var prLabels map[string]string
-	for _, l := range e.GetIssue().Labels {
-		prLabels[l.GetName()] = l.GetName()
-	}
-	if _, ok := prLabels["merge/squash"]; ok {
-		return bot.cli.CreatePRComment(gc.PRInfo{Org: org, Repo: repo, Number: number},
+	if ev.labels.Has("merge/squash") {
+		return bot.cli.CreatePRComment(ev.pr,
 			"Please use **/squash cancel** to remove **merge/squash** label, and try **/rebase** again")
 	}
 
-	return bot.cli.AddPRLabel(gc.PRInfo{Org: org, Repo: repo, Number: number}, "merge/rebase")
+	return bot.cli.AddPRLabel(ev.pr, "merge/rebase")
 }
 
-func (bot *robot) handleFlattened(e *sdk.IssueCommentEvent, cfg *botConfig, log *logrus.Entry) error {
-	if !e.GetIssue().IsPullRequest() ||
-		e.GetIssue().GetState() != open ||
-		!gc.IsCommentCreated(e) ||
-		e.GetComment().GetBody() != squashCommand {
-		return nil
+func (bot *robot) handleFlattened(ev *commandEvent, cmd command, cfg *botConfig, log *logrus.Entry) error {
+	if cmdRemoveSquash.match(cmd) {
+		return bot.removeFlattened(ev, cfg, log)
 	}
 
-	org, repo := gc.GetOrgRepo(e.GetRepo())
-	number := e.GetIssue().GetNumber()
-	commenter := e.GetComment().GetUser().GetLogin()
-
-	hasPermission, err := bot.hasPermission(org, repo, commenter, false, e, cfg, log)
-	if err != nil {
-		return err
-	}
-
-	if !hasPermission {
+	if !cmdSquash.match(cmd) {
 		return nil
 	}
 
-	var prLabels map[string]string
-	for _, l := range e.GetIssue().Labels {
-		prLabels[l.GetName()] = l.GetName()
-	}
-	if _, ok := prLabels["merge/rebase"]; ok {
-		return bot.cli.CreatePRComment(gc.PRInfo{Org: org, Repo: repo, Number: number},
+	if ev.labels.Has("merge/rebase") {
+		return bot.cli.CreatePRComment(ev.pr,
 			"Please use **/rebase cancel** to remove **merge/rebase** label, and try **/squash** again")
 	}
 
-	return bot.cli.AddPRLabel(gc.PRInfo{Org: org, Repo: repo, Number: number}, "merge/squash")
+	return bot.cli.AddPRLabel(ev.pr, "merge/squash")
 }
 
 func (bot *robot) doRetest(e *sdk.PullRequestEvent, p gc.PRInfo) error {
@@ -133,7 +89,7 @@ func (bot *robot) doRetest(e *sdk.PullRequestEvent, p gc.PRInfo) error {
 	return bot.cli.CreatePRComment(p, retestCommand)
 }
 
-func (bot *robot) checkReviewer(e *sdk.PullRequestEvent, p gc.PRInfo, cfg *botConfig) error {
+func (bot *robot) checkReviewer(e *sdk.PullRequestEvent, p gc.PRInfo, cfg *botConfig, log *logrus.Entry) error {
 	if cfg.UnableCheckingReviewerForPR || e.GetPullRequest().GetState() != open {
 		return nil
 	}
@@ -142,10 +98,22 @@ func (bot *robot) checkReviewer(e *sdk.PullRequestEvent, p gc.PRInfo, cfg *botCo
 		return nil
 	}
 
+	// suggesting or assigning reviewers is costly, so it is done only when the pr is ready to review.
+	if cfg.ReviewerAssignment.Mode != reviewerModeNotify && isReadyToReview(e.GetAction()) {
+		done, err := bot.assignReviewers(e.GetPullRequest(), p, cfg, log)
+		if err != nil {
+			log.WithError(err).Error("assign reviewers")
+		}
+
+		if done {
+			return err
+		}
+	}
+
 	return bot.cli.CreatePRComment(p, fmt.Sprintf(msgNotSetReviewer, e.GetPullRequest().GetUser().GetLogin()))
 }
 
-func (bot *robot) clearLabel(e *sdk.PullRequestEvent, p gc.PRInfo) error {
+func (bot *robot) clearLabel(e *sdk.PullRequestEvent, p gc.PRInfo, cfg *botConfig, log *logrus.Entry) error {
 	if e.GetAction() != sourceBranchChanged || e.GetPullRequest().GetState() != open {
 		return nil
 	}
@@ -160,13 +128,73 @@ func (bot *robot) clearLabel(e *sdk.PullRequestEvent, p gc.PRInfo) error {
 		v = append(v, approvedLabel)
 	}
 
-	if len(v) > 0 {
-		for _, vv := range v {
-			if err := bot.cli.RemovePRLabel(p, vv); err != nil {
-				return err
+	var decisions []labelDecision
+
+	if len(v) > 0 || labels.Has(changesRequestedLabel) {
+		files, known, err := bot.filesChangedByPush(e, p)
+		if err != nil {
+			log.WithError(err).Error("compare the changes before and after push")
+		}
+
+		if known && len(files) == 0 {
+			if labels.Has(freezeExceptionLabel) {
+				if err := bot.cli.RemovePRLabel(p, freezeExceptionLabel); err != nil {
+					return err
+				}
 			}
+
+			if labels.Has(changesRequestedLabel) {
+				v = append(v, changesRequestedLabel)
+			}
+
+			return bot.cli.CreatePRComment(p, fmt.Sprintf(commentKeepLabel, strings.Join(v, ", ")))
+		}
+
+		if known && cfg.ScopeAwareInvalidation && len(v) > 0 {
+			decisions, err = bot.decideLabelsByScope(e.GetPullRequest(), p, cfg, v, files, log)
+			if err != nil {
+				log.WithError(err).Error("decide labels by the scope of reviewers")
+			}
+		}
+	}
+
+	if decisions != nil {
+		v = nil
+		for _, d := range decisions {
+			if !d.keep {
+				v = append(v, d.label)
+			}
+		}
+	}
+
+	for _, l := range []string{changesRequestedLabel, freezeExceptionLabel} {
+		if !labels.Has(l) {
+			continue
+		}
+
+		v = append(v, l)
+
+		if decisions != nil {
+			decisions = append(decisions, labelDecision{label: l, reason: reasonNewChanges})
+		}
+	}
+
+	for _, vv := range v {
+		if err := bot.cli.RemovePRLabel(p, vv); err != nil {
+			return err
 		}
+	}
 
+	if len(decisions) > 0 {
+		s := make([]string, len(decisions))
+		for i := range decisions {
+			s[i] = decisions[i].String()
+		}
+
+		return bot.cli.CreatePRComment(p, fmt.Sprintf(commentScopedClearLabel, strings.Join(s, "\n")))
+	}
+
+	if len(v) > 0 {
 		return bot.cli.CreatePRComment(p, fmt.Sprintf(commentClearLabel, strings.Join(v, ", ")))
 	}
 
@@ -200,7 +228,7 @@ func (bot *robot) genMergeMethod(e *sdk.PullRequest, org, repo string, log *logr
 	sig := strings.Split(sigLabel, "/")[1]
 	filePath := fmt.Sprintf("sig/%s/%s/%s/%s", sig, org, strings.ToLower(repo[0:1]), fmt.Sprintf("%s.yaml", repo))
 
-	c, err := bot.cli.GetPathContent("openeuler", "community", filePath, "master")
+	c, err := bot.cache.getFileContent("openeuler", "community", "master", filePath)
 	if err != nil {
 		log.Infof("get repo %s failed, because of %v", fmt.Sprintf("%s-%s", org, repo), err)
 
@@ -212,87 +240,24 @@ func (bot *robot) genMergeMethod(e *sdk.PullRequest, org, repo string, log *logr
 	return mergeMethod
 }
 
-func (bot *robot) removeRebase(e *sdk.IssueCommentEvent, cfg *botConfig, log *logrus.Entry) error {
-	if !e.GetIssue().IsPullRequest() ||
-		e.GetIssue().GetState() != open ||
-		!gc.IsCommentCreated(e) ||
-		e.GetComment().GetBody() != removeRebase {
-		return nil
-	}
-
-	org, repo := gc.GetOrgRepo(e.GetRepo())
-	number := e.GetIssue().GetNumber()
-	commenter := e.GetComment().GetUser().GetLogin()
-
-	hasPermission, err := bot.hasPermission(org, repo, commenter, false, e, cfg, log)
-	if err != nil {
-		return err
-	}
-
-	if !hasPermission {
-		return nil
-	}
-
-	return bot.cli.RemovePRLabel(gc.PRInfo{Org: org, Repo: repo, Number: number}, "merge/rebase")
+func (bot *robot) removeRebase(ev *commandEvent, cfg *botConfig, log *logrus.Entry) error {
+	return bot.cli.RemovePRLabel(ev.pr, "merge/rebase")
 }
 
-func (bot *robot) removeFlattened(e *sdk.IssueCommentEvent, cfg *botConfig, log *logrus.Entry) error {
-	if !e.GetIssue().IsPullRequest() ||
-		e.GetIssue().GetState() != open ||
-		!gc.IsCommentCreated(e) ||
-		e.GetComment().GetBody() != removeSquash {
-		return nil
-	}
-
-	org, repo := gc.GetOrgRepo(e.GetRepo())
-	number := e.GetIssue().GetNumber()
-	commenter := e.GetComment().GetUser().GetLogin()
-
-	hasPermission, err := bot.hasPermission(org, repo, commenter, false, e, cfg, log)
-	if err != nil {
-		return err
-	}
-
-	if !hasPermission {
-		return nil
-	}
-
-	return bot.cli.RemovePRLabel(gc.PRInfo{Org: org, Repo: repo, Number: number}, "merge/squash")
+func (bot *robot) removeFlattened(ev *commandEvent, cfg *botConfig, log *logrus.Entry) error {
+	return bot.cli.RemovePRLabel(ev.pr, "merge/squash")
 }
 
-func (bot *robot) handleACK(e *sdk.IssueCommentEvent, cfg *botConfig, log *logrus.Entry) error {
-	if !e.GetIssue().IsPullRequest() ||
-		e.GetIssue().GetState() != open ||
-		!gc.IsCommentCreated(e) {
-		return nil
-	}
-
-	if !ackCommand.MatchString(e.GetComment().GetBody()) {
-		return nil
-	}
-
-	org, repo := gc.GetOrgRepo(e.GetRepo())
-	if org != "openeuler" && repo != "kernel" {
-		return nil
-	}
-
-	number := e.GetIssue().GetNumber()
-	commenter := e.GetComment().GetUser().GetLogin()
-
-	hasPermission, err := bot.hasPermission(org, repo, commenter, false, e, cfg, log)
-	if err != nil {
-		return err
-	}
-
-	if !hasPermission {
+func (bot *robot) handleACK(ev *commandEvent, cmd command, cfg *botConfig, log *logrus.Entry) error {
+	if !cmdAck.match(cmd) {
 		return nil
 	}
 
-	return bot.cli.AddPRLabel(gc.PRInfo{Org: org, Repo: repo, Number: number}, ackLabel)
+	return bot.cli.AddPRLabel(ev.pr, ackLabel)
 }
 
-func (bot *robot) decodeRepoYaml(content *sdk.RepositoryContent, log *logrus.Entry) string {
-	c, err := base64.StdEncoding.DecodeString(*content.Content)
+func (bot *robot) decodeRepoYaml(content string, log *logrus.Entry) string {
+	c, err := base64.StdEncoding.DecodeString(content)
 	if err != nil {
 		log.WithError(err).Error("decode file")
 
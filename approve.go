@@ -2,93 +2,129 @@ package main
 
 import (
 	"fmt"
-	"regexp"
+	"strings"
 
 	gc "github.com/opensourceways/robot-github-lib/client"
-
-	sdk "github.com/google/go-github/v36/github"
 	"github.com/sirupsen/logrus"
 )
 
-const approvedLabel = "approved"
+const (
+	approvedLabel = "approved"
 
-var (
-	regAddApprove    = regexp.MustCompile(`(?mi)^/approve\s*$`)
-	regRemoveApprove = regexp.MustCompile(`(?mi)^/approve cancel\s*$`)
+	commentPartiallyApproved = `***%s*** approved the files owned by it in this pull request. :wave:
+The following files still need to be approved by their approvers:
+%s`
+	commentStillApproved = `The approval of ***%s*** was cancelled, but all the files of this pull request are still approved by other approvers.`
 )
 
-func (bot *robot) handleApprove(e *sdk.IssueCommentEvent, cfg *botConfig, log *logrus.Entry) error {
-	if !e.GetIssue().IsPullRequest() || !(e.GetIssue().GetState() == open) || !gc.IsCommentCreated(e) {
-		return nil
-	}
+var (
+	cmdAddApprove    = newCommandMatcher("/approve")
+	cmdRemoveApprove = newCommandMatcher("/approve cancel")
+)
 
-	comment := e.GetComment().GetBody()
-	if regAddApprove.MatchString(comment) {
-		return bot.AddApprove(cfg, e, log)
+func (bot *robot) handleApprove(ev *commandEvent, cmd command, cfg *botConfig, log *logrus.Entry) error {
+	if cmdAddApprove.match(cmd) {
+		return bot.AddApprove(cfg, ev, log)
 	}
 
-	if regRemoveApprove.MatchString(comment) {
-		return bot.removeApprove(cfg, e, log)
+	if cmdRemoveApprove.match(cmd) {
+		return bot.removeApprove(cfg, ev, log)
 	}
 
 	return nil
 }
 
-func (bot *robot) AddApprove(cfg *botConfig, e *sdk.IssueCommentEvent, log *logrus.Entry) error {
-	org, repo := gc.GetOrgRepo(e.GetRepo())
-	number := e.GetIssue().GetNumber()
-	commenter := e.GetComment().GetUser().GetLogin()
-
-	pr := gc.PRInfo{Org: org, Repo: repo, Number: number}
+func (bot *robot) AddApprove(cfg *botConfig, ev *commandEvent, log *logrus.Entry) error {
+	pr, commenter := ev.pr, ev.commenter
 
-	v, err := bot.hasPermission(org, repo, commenter, false, e, cfg, log)
-	if err != nil {
-		return err
+	if cfg.ApproveBasedOnOwners {
+		return bot.addApproveByOwners(cfg, pr, ev.author, commenter, log)
 	}
 
-	if !v {
-		return bot.cli.CreatePRComment(pr, fmt.Sprintf(
-			commentNoPermissionForLabel, commenter, "add", approvedLabel,
-		))
-	}
+	return bot.addApprovedLabel(cfg, pr, commenter, log)
+}
 
+func (bot *robot) addApprovedLabel(cfg *botConfig, pr gc.PRInfo, commenter string, log *logrus.Entry) error {
 	if err := bot.cli.AddPRLabel(pr, approvedLabel); err != nil {
 		return err
 	}
 
-	err = bot.cli.CreatePRComment(
+	err := bot.cli.CreatePRComment(
 		pr, fmt.Sprintf(commentAddLabel, approvedLabel, commenter),
 	)
 	if err != nil {
 		log.Error(err)
 	}
 
-	return bot.tryMerge(e, cfg, false, log)
+	return bot.tryMerge(pr, commenter, cfg, false, log)
 }
 
-func (bot *robot) removeApprove(cfg *botConfig, e *sdk.IssueCommentEvent, log *logrus.Entry) error {
-	org, repo := gc.GetOrgRepo(e.GetRepo())
-	number := e.GetIssue().GetNumber()
-	commenter := e.GetComment().GetUser().GetLogin()
+func (bot *robot) removeApprove(cfg *botConfig, ev *commandEvent, log *logrus.Entry) error {
+	pr, commenter := ev.pr, ev.commenter
+
+	if cfg.ApproveBasedOnOwners {
+		return bot.removeApproveByOwners(pr, ev.author, commenter, log)
+	}
+
+	if err := bot.cli.RemovePRLabel(pr, approvedLabel); err != nil {
+		return err
+	}
 
-	pr := gc.PRInfo{Org: org, Repo: repo, Number: number}
+	return bot.cli.CreatePRComment(
+		pr, fmt.Sprintf(commentRemovedLabel, approvedLabel, commenter),
+	)
+}
 
-	v, err := bot.hasPermission(org, repo, commenter, false, e, cfg, log)
+func (bot *robot) newApproveHelper(pr gc.PRInfo, log *logrus.Entry) (*approveHelper, error) {
+	sp, err := bot.cli.GetSinglePR(pr.Org, pr.Repo, pr.Number)
+	if err != nil {
+		return nil, err
+	}
+
+	return newApproveHelper(bot.cache, bot.cli, pr, sp.GetBase().GetRef(), log)
+}
+
+func (bot *robot) addApproveByOwners(
+	cfg *botConfig, pr gc.PRInfo, author, commenter string, log *logrus.Entry,
+) error {
+	h, err := bot.newApproveHelper(pr, log)
 	if err != nil {
 		return err
 	}
 
-	if !v {
+	files, err := h.unapprovedFiles(author)
+	if err != nil {
+		return err
+	}
+
+	if len(files) > 0 {
 		return bot.cli.CreatePRComment(pr, fmt.Sprintf(
-			commentNoPermissionForLabel, commenter, "remove", approvedLabel,
+			commentPartiallyApproved, commenter, strings.Join(files, "\n"),
 		))
 	}
 
-	err = bot.cli.RemovePRLabel(pr, approvedLabel)
+	return bot.addApprovedLabel(cfg, pr, commenter, log)
+}
+
+func (bot *robot) removeApproveByOwners(pr gc.PRInfo, author, commenter string, log *logrus.Entry) error {
+	h, err := bot.newApproveHelper(pr, log)
+	if err != nil {
+		return err
+	}
+
+	files, err := h.unapprovedFiles(author)
 	if err != nil {
 		return err
 	}
 
+	if len(files) == 0 {
+		return bot.cli.CreatePRComment(pr, fmt.Sprintf(commentStillApproved, commenter))
+	}
+
+	if err = bot.cli.RemovePRLabel(pr, approvedLabel); err != nil {
+		return err
+	}
+
 	return bot.cli.CreatePRComment(
 		pr, fmt.Sprintf(commentRemovedLabel, approvedLabel, commenter),
 	)
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	gc "github.com/opensourceways/robot-github-lib/client"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	assignCommand   = "assign"
+	unassignCommand = "unassign"
+	ccCommand       = "cc"
+
+	msgInvalidReviewers       = "@%s , these users are neither collaborators, owners of the changed files nor members of the sig of repository, so they can not be %s: %s"
+	msgNoPermissionOfUnassign = "@%s , only the author, collaborators and the assignees themselves can unassign: %s"
+)
+
+func (bot *robot) handleAssign(ev *commandEvent, cmd command, cfg *botConfig, log *logrus.Entry) error {
+	logins := parseLogins(cmd.args)
+	if len(logins) == 0 {
+		logins = []string{ev.commenter}
+	}
+
+	h := assignHelper{
+		bot:    bot,
+		cfg:    cfg,
+		pr:     ev.pr,
+		author: ev.author,
+		log:    log,
+	}
+
+	return h.handle(cmd.name, ev.commenter, sets.NewString(logins...).List())
+}
+
+type assignHelper struct {
+	bot    *robot
+	cfg    *botConfig
+	pr     gc.PRInfo
+	author string
+	log    *logrus.Entry
+
+	files   []string
+	owners  repoOwners
+	sigs    map[string]sets.String
+	members sets.String
+}
+
+func (h *assignHelper) handle(cmd, commenter string, logins []string) error {
+	cli := h.bot.cli
+
+	if cmd == unassignCommand {
+		if commenter != h.author && !(len(logins) == 1 && strings.EqualFold(logins[0], commenter)) {
+			b, err := hasWritePermission(cli, h.pr.Org, h.pr.Repo, commenter)
+			if err != nil {
+				return err
+			}
+
+			if !b {
+				return cli.CreatePRComment(h.pr, fmt.Sprintf(
+					msgNoPermissionOfUnassign, commenter, strings.Join(logins, ", "),
+				))
+			}
+		}
+
+		return cli.UnAssignPR(h.pr, logins)
+	}
+
+	var valid, invalid []string
+	for _, login := range logins {
+		if cmd == ccCommand && strings.EqualFold(login, h.author) {
+			continue
+		}
+
+		b, err := h.isValidReviewer(login)
+		if err != nil {
+			return err
+		}
+
+		if b {
+			valid = append(valid, login)
+		} else {
+			invalid = append(invalid, login)
+		}
+	}
+
+	if len(invalid) > 0 {
+		action := "assigned"
+		if cmd == ccCommand {
+			action = "requested to review"
+		}
+
+		if err := cli.CreatePRComment(h.pr, fmt.Sprintf(
+			msgInvalidReviewers, commenter, action, strings.Join(invalid, ", "),
+		)); err != nil {
+			h.log.WithError(err).Error("comment on invalid reviewers")
+		}
+	}
+
+	if len(valid) == 0 {
+		return nil
+	}
+
+	if cmd == ccCommand {
+		return cli.RequestReviewers(h.pr, valid)
+	}
+
+	return cli.AssignPR(h.pr, valid)
+}
+
+// isValidReviewer tells whether the user is a collaborator of repository, or is declared
+// by the OWNERS files of changed files, or is an owner of the sig of changed files, or is
+// a maintainer or committer of the sig of repository. They are the same as the candidates
+// suggested by candidateReviewers.
+func (h *assignHelper) isValidReviewer(login string) (bool, error) {
+	b, err := h.bot.cli.IsCollaborator(h.pr, login)
+	if err != nil || b {
+		return b, err
+	}
+
+	if err := h.loadOwners(); err != nil {
+		return false, err
+	}
+
+	login = strings.ToLower(login)
+
+	if h.members.Has(login) {
+		return true, nil
+	}
+
+	for _, f := range h.files {
+		if h.owners.reviewersOf(f).Has(login) {
+			return true, nil
+		}
+
+		if o, ok := h.sigs[path.Dir(f)]; ok && o.Has(login) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (h *assignHelper) loadOwners() error {
+	if h.files != nil {
+		return nil
+	}
+
+	sp, err := h.bot.cli.GetSinglePR(h.pr.Org, h.pr.Repo, h.pr.Number)
+	if err != nil {
+		return err
+	}
+
+	owners, err := h.bot.cache.getRepoOwners(h.pr.Org, h.pr.Repo, sp.GetBase().GetRef(), h.log)
+	if err != nil {
+		return err
+	}
+
+	files, err := getChangedFiles(h.bot.cli, h.pr)
+	if err != nil {
+		return err
+	}
+
+	if h.cfg.CheckPermissionBasedOnSigOwners {
+		sigs, err := h.bot.cache.getSigOwners(h.pr.Org, h.pr.Repo, "master", h.cfg, h.log)
+		if err != nil {
+			h.log.WithError(err).Error("get owners of sigs")
+		}
+
+		h.sigs = sigs
+	}
+
+	h.members = sets.NewString()
+	if c := h.cfg.ReviewerAssignment.Community; c != nil {
+		if v, ok := h.bot.cache.getSigMembersOfRepos(c, h.log)[h.pr.Org+"/"+h.pr.Repo]; ok {
+			h.members = v.all()
+		}
+	}
+
+	h.owners = owners
+	h.files = files
+
+	return nil
+}
+
+// parseLogins returns the logins in the arguments of command, the leading @ is trimmed.
+func parseLogins(args []string) []string {
+	var r []string
+
+	for _, v := range args {
+		if v = strings.TrimPrefix(v, "@"); v != "" {
+			r = append(r, v)
+		}
+	}
+
+	return r
+}
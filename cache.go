@@ -0,0 +1,280 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/opensourceways/repo-file-cache/models"
+	cache "github.com/opensourceways/repo-file-cache/sdk"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const platformGithub = "github"
+
+// fileCache reads the files of repositories through the repo file cache.
+// It falls back to the github api when the cache has no record of the branch.
+// The data parsed from the cached files is indexed in process by the sha of branch,
+// so it is parsed only once for each commit of the branch.
+type fileCache struct {
+	cli      iClient
+	cacheCli *cache.SDK
+
+	lock    sync.Mutex
+	indexes map[string]indexItem
+}
+
+type indexItem struct {
+	sha string
+	v   interface{}
+}
+
+func newFileCache(cacheCli *cache.SDK, cli iClient) *fileCache {
+	return &fileCache{
+		cli:      cli,
+		cacheCli: cacheCli,
+		indexes:  make(map[string]indexItem),
+	}
+}
+
+// getFiles returns all the files named fileName in the branch.
+// The BranchSHA of result is empty if the branch is not cached.
+func (fc *fileCache) getFiles(org, repo, branch, fileName string) (models.FilesInfo, error) {
+	if fc.cacheCli == nil {
+		return models.FilesInfo{}, nil
+	}
+
+	b := models.Branch{
+		Platform: platformGithub,
+		Org:      org,
+		Repo:     repo,
+		Branch:   branch,
+	}
+
+	return fc.cacheCli.GetFiles(b, fileName, false)
+}
+
+// getFileContent returns the base64 encoded content of file.
+func (fc *fileCache) getFileContent(org, repo, branch, filePath string) (string, error) {
+	info, err := fc.getFiles(org, repo, branch, path.Base(filePath))
+	if err == nil && info.BranchSHA != "" {
+		for i := range info.Files {
+			if f := &info.Files[i]; f.Path.FullPath() == filePath {
+				return f.Content, nil
+			}
+		}
+	}
+
+	c, err := fc.cli.GetPathContent(org, repo, filePath, branch)
+	if err != nil {
+		return "", err
+	}
+
+	if c == nil || c.Content == nil {
+		return "", fmt.Errorf("no content of %s/%s/%s:%s", org, repo, branch, filePath)
+	}
+
+	return *c.Content, nil
+}
+
+// getIndex returns the index of key which is generated at the sha of branch.
+// It calls build to regenerate the index when the branch has changed.
+func (fc *fileCache) getIndex(key, sha string, build func() interface{}) interface{} {
+	fc.lock.Lock()
+	item, ok := fc.indexes[key]
+	fc.lock.Unlock()
+
+	if ok && item.sha == sha {
+		return item.v
+	}
+
+	v := build()
+
+	fc.lock.Lock()
+	fc.indexes[key] = indexItem{sha: sha, v: v}
+	fc.lock.Unlock()
+
+	return v
+}
+
+// getSigOwners returns the owners of each sig directory. The owners of a sig are
+// read from the OWNERS file in the sig directory, or from the maintainers of
+// sig-info.yaml when there is no OWNERS file.
+func (fc *fileCache) getSigOwners(
+	org, repo, branch string, cfg *botConfig, log *logrus.Entry,
+) (map[string]sets.String, error) {
+	oInfo, err := fc.getFiles(org, repo, branch, ownerFile)
+	if err != nil {
+		log.WithError(err).Error("get owners files from cache")
+	}
+
+	sInfo, err := fc.getFiles(org, repo, branch, sigInfoFile)
+	if err != nil {
+		log.WithError(err).Error("get sig info files from cache")
+	}
+
+	if oInfo.BranchSHA == "" && sInfo.BranchSHA == "" {
+		return fc.loadSigOwners(org, repo, branch, cfg, log)
+	}
+
+	key := fmt.Sprintf("sig-owners:%s/%s/%s:%s", org, repo, branch, cfg.SigsDir)
+	sha := oInfo.BranchSHA + "/" + sInfo.BranchSHA
+
+	v := fc.getIndex(key, sha, func() interface{} {
+		r := make(map[string]sets.String)
+
+		for i := range sInfo.Files {
+			f := &sInfo.Files[i]
+			if isFileOfSigDir(f.Path.FullPath(), cfg) {
+				r[f.Dir()] = decodeSigInfoFile(f.Content, log)
+			}
+		}
+
+		for i := range oInfo.Files {
+			f := &oInfo.Files[i]
+			if isFileOfSigDir(f.Path.FullPath(), cfg) {
+				r[f.Dir()] = decodeOwnerFile(f.Content, log)
+			}
+		}
+
+		return r
+	})
+
+	return v.(map[string]sets.String), nil
+}
+
+// loadSigOwners loads the owners of each sig directory through the github api.
+func (fc *fileCache) loadSigOwners(
+	org, repo, branch string, cfg *botConfig, log *logrus.Entry,
+) (map[string]sets.String, error) {
+	oPath, sPath, err := listDirectoryTree(fc.cli, org, repo, branch, cfg.SigsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	r := make(map[string]sets.String)
+
+	for _, s := range sPath {
+		c, err := fc.cli.GetPathContent(org, repo, s, branch)
+		if err != nil || c == nil || c.Content == nil {
+			return nil, fmt.Errorf("get file:%s, err:%v", s, err)
+		}
+
+		r[path.Dir(s)] = decodeSigInfoFile(*c.Content, log)
+	}
+
+	for _, o := range oPath {
+		c, err := fc.cli.GetPathContent(org, repo, o, branch)
+		if err != nil || c == nil || c.Content == nil {
+			return nil, fmt.Errorf("get file:%s, err:%v", o, err)
+		}
+
+		r[path.Dir(o)] = decodeOwnerFile(*c.Content, log)
+	}
+
+	return r, nil
+}
+
+// getRepoOwners returns all the OWNERS files of the branch.
+func (fc *fileCache) getRepoOwners(org, repo, branch string, log *logrus.Entry) (repoOwners, error) {
+	info, err := fc.getFiles(org, repo, branch, ownerFile)
+	if err != nil {
+		log.WithError(err).Error("get owners files from cache")
+	}
+
+	if info.BranchSHA == "" {
+		return loadRepoOwners(fc.cli, org, repo, branch, log)
+	}
+
+	key := fmt.Sprintf("owners:%s/%s/%s", org, repo, branch)
+
+	v := fc.getIndex(key, info.BranchSHA, func() interface{} {
+		r := make(repoOwners)
+
+		for i := range info.Files {
+			f := &info.Files[i]
+			if o := decodeOwnersConfig(f.Content, log); o != nil {
+				r[f.Dir()] = o
+			}
+		}
+
+		return r
+	})
+
+	return v.(repoOwners), nil
+}
+
+// sigMembers are the maintainers and committers of the sig which a repository belongs to.
+type sigMembers struct {
+	maintainers sets.String
+	committers  sets.String
+}
+
+func (m sigMembers) all() sets.String {
+	return m.maintainers.Union(m.committers)
+}
+
+// getSigMembersOfRepos returns the maintainers and committers of the sig which each
+// repository belongs to. It is keyed by the full name of repository, such as openeuler/kernel.
+// The sig-info.yaml files of community are only read through the cache.
+func (fc *fileCache) getSigMembersOfRepos(c *repoBranch, log *logrus.Entry) map[string]sigMembers {
+	info, err := fc.getFiles(c.Owner, c.Repo, c.Branch, sigInfoFile)
+	if err != nil {
+		log.WithError(err).Error("get sig info files from cache")
+	}
+
+	if info.BranchSHA == "" {
+		log.Warnf("the sig info files of %s/%s/%s are not cached", c.Owner, c.Repo, c.Branch)
+
+		return nil
+	}
+
+	key := fmt.Sprintf("sig-members:%s/%s/%s", c.Owner, c.Repo, c.Branch)
+
+	v := fc.getIndex(key, info.BranchSHA, func() interface{} {
+		r := make(map[string]sigMembers)
+
+		for i := range info.Files {
+			s, err := decodeSigInfos(info.Files[i].Content)
+			if err != nil {
+				log.WithError(err).Errorf("decode %s", info.Files[i].Path.FullPath())
+
+				continue
+			}
+
+			maintainers := sets.NewString()
+			for _, m := range s.Maintainers {
+				maintainers.Insert(strings.ToLower(m.GiteeID))
+			}
+
+			for _, item := range s.Repositories {
+				committers := sets.NewString()
+				for _, m := range item.Committers {
+					committers.Insert(strings.ToLower(m.GiteeID))
+				}
+
+				for _, repo := range item.Repo {
+					v, ok := r[repo]
+					if !ok {
+						v = sigMembers{maintainers: sets.NewString(), committers: sets.NewString()}
+					}
+
+					r[repo] = sigMembers{
+						maintainers: v.maintainers.Union(maintainers),
+						committers:  v.committers.Union(committers),
+					}
+				}
+			}
+		}
+
+		return r
+	})
+
+	return v.(map[string]sigMembers)
+}
+
+func isFileOfSigDir(p string, cfg *botConfig) bool {
+	return cfg.regSigDir.MatchString(p) && strings.Count(p, "/") == 2
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	sdk "github.com/google/go-github/v36/github"
+	gc "github.com/opensourceways/robot-github-lib/client"
+	"github.com/opensourceways/server-common-lib/config"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// checkHookPath receives the check_run and check_suite events, which are
+	// not dispatched by the github framework on its own hook.
+	checkHookPath = "/github-check-hook"
+
+	checkCompleted = "completed"
+)
+
+// checkHook handles the check_run and check_suite events in the same way as the status events,
+// so the PRs whose CI reports through GitHub Actions are merged once the checks succeed.
+type checkHook struct {
+	bot   *robot
+	agent *config.ConfigAgent
+
+	// wg tracks the running handlers for graceful shutdown.
+	wg sync.WaitGroup
+}
+
+func (h *checkHook) Wait() {
+	h.wg.Wait()
+}
+
+func (h *checkHook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	if r.Header.Get("User-Agent") != "Robot-Github-Access" {
+		http.Error(w, "400 Bad Request: unknown User-Agent Header", http.StatusBadRequest)
+
+		return
+	}
+
+	eventType := r.Header.Get("X-GitHub-Event")
+	if eventType == "" {
+		http.Error(w, "400 Bad Request: Missing X-GitHub-Event Header", http.StatusBadRequest)
+
+		return
+	}
+
+	payload, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "500 Internal Server Error: Failed to read request body", http.StatusInternalServerError)
+
+		return
+	}
+
+	l := logrus.WithFields(logrus.Fields{
+		"event-type": eventType,
+		"event_id":   r.Header.Get("X-GitHub-Delivery"),
+	})
+
+	hook, err := sdk.ParseWebHook(eventType, payload)
+	if err != nil {
+		l.WithError(err).Error()
+
+		return
+	}
+
+	var repo *sdk.Repository
+	var sha string
+
+	switch e := hook.(type) {
+	case *sdk.CheckRunEvent:
+		if e.GetAction() != checkCompleted || e.GetCheckRun().GetConclusion() != statusSuccess {
+			return
+		}
+
+		repo, sha = e.GetRepo(), e.GetCheckRun().GetHeadSHA()
+
+	case *sdk.CheckSuiteEvent:
+		if e.GetAction() != checkCompleted || e.GetCheckSuite().GetConclusion() != statusSuccess {
+			return
+		}
+
+		repo, sha = e.GetRepo(), e.GetCheckSuite().GetHeadSHA()
+
+	default:
+		l.Debug("Ignoring unknown event type")
+
+		return
+	}
+
+	h.wg.Add(1)
+
+	go func() {
+		defer h.wg.Done()
+
+		if err := h.handle(repo, sha, l); err != nil {
+			l.WithError(err).Error()
+		} else {
+			l.Info()
+		}
+	}()
+}
+
+func (h *checkHook) handle(repo *sdk.Repository, sha string, log *logrus.Entry) error {
+	_, c := h.agent.GetConfig()
+
+	org, name := gc.GetOrgRepo(repo)
+	cfg, err := h.bot.getConfig(c, org, name)
+	if err != nil {
+		return err
+	}
+
+	return h.bot.tryMergeBySHA(org, name, sha, cfg, log)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	sdk "github.com/google/go-github/v36/github"
+	gc "github.com/opensourceways/robot-github-lib/client"
+	"golang.org/x/oauth2"
+)
+
+// githubClient adds the apis which are not provided by the client of robot-github-lib.
+type githubClient struct {
+	gc.Client
+
+	c *sdk.Client
+}
+
+func newGithubClient(getToken func() []byte) githubClient {
+	ts := oauth2.StaticTokenSource(&oauth2.Token{
+		AccessToken: string(getToken()),
+	})
+
+	return githubClient{
+		Client: gc.NewClient(getToken),
+		c:      sdk.NewClient(oauth2.NewClient(context.Background(), ts)),
+	}
+}
+
+func (cl githubClient) RequestReviewers(pr gc.PRInfo, logins []string) error {
+	_, _, err := cl.c.PullRequests.RequestReviewers(
+		context.Background(),
+		pr.Org, pr.Repo, pr.Number, sdk.ReviewersRequest{Reviewers: logins},
+	)
+
+	return err
+}
+
+// CompareCommits compares head with the merge base of base and head.
+func (cl githubClient) CompareCommits(org, repo, base, head string) (*sdk.CommitsComparison, error) {
+	v, _, err := cl.c.Repositories.CompareCommits(context.Background(), org, repo, base, head)
+
+	return v, err
+}
+
+// IsTeamMember tells whether the user is an active member of the team of organization.
+func (cl githubClient) IsTeamMember(org, team, login string) (bool, error) {
+	v, resp, err := cl.c.Teams.GetTeamMembershipBySlug(context.Background(), org, team, login)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return v.GetState() == "active", nil
+}
+
+// ListReviews lists all the reviews of pr in the order of submission.
+func (cl githubClient) ListReviews(pr gc.PRInfo) ([]*sdk.PullRequestReview, error) {
+	var r []*sdk.PullRequestReview
+
+	opt := &sdk.ListOptions{PerPage: 100}
+	for {
+		v, resp, err := cl.c.PullRequests.ListReviews(context.Background(), pr.Org, pr.Repo, pr.Number, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		r = append(r, v...)
+
+		if resp.NextPage == 0 {
+			return r, nil
+		}
+
+		opt.Page = resp.NextPage
+	}
+}
+
+// ListIssueEvents lists all the events of pr, such as the dismissal of reviews.
+func (cl githubClient) ListIssueEvents(pr gc.PRInfo) ([]*sdk.IssueEvent, error) {
+	var r []*sdk.IssueEvent
+
+	opt := &sdk.ListOptions{PerPage: 100}
+	for {
+		v, resp, err := cl.c.Issues.ListIssueEvents(context.Background(), pr.Org, pr.Repo, pr.Number, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		r = append(r, v...)
+
+		if resp.NextPage == 0 {
+			return r, nil
+		}
+
+		opt.Page = resp.NextPage
+	}
+}
+
+// GetBot returns the login of the user who owns the token.
+func (cl githubClient) GetBot() (string, error) {
+	v, _, err := cl.c.Users.Get(context.Background(), "")
+	if err != nil {
+		return "", err
+	}
+
+	return v.GetLogin(), nil
+}
+
+// GetUser returns the account of user, its type tells whether it is a bot.
+func (cl githubClient) GetUser(login string) (*sdk.User, error) {
+	v, _, err := cl.c.Users.Get(context.Background(), login)
+
+	return v, err
+}
+
+// ListReviewComments lists all the inline review comments of pr.
+func (cl githubClient) ListReviewComments(pr gc.PRInfo) ([]*sdk.PullRequestComment, error) {
+	var r []*sdk.PullRequestComment
+
+	opt := &sdk.PullRequestListCommentsOptions{ListOptions: sdk.ListOptions{PerPage: 100}}
+	for {
+		v, resp, err := cl.c.PullRequests.ListComments(context.Background(), pr.Org, pr.Repo, pr.Number, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		r = append(r, v...)
+
+		if resp.NextPage == 0 {
+			return r, nil
+		}
+
+		opt.Page = resp.NextPage
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	regCommandLine = regexp.MustCompile(`^/([A-Za-z][-\w]*)(\s+.*)?$`)
+	regHTMLComment = regexp.MustCompile(`<!--.*?-->`)
+)
+
+// command is a slash command in a comment, such as /lgtm or /assign @user.
+type command struct {
+	// name is the lower case name of command without the leading slash.
+	name string
+	args []string
+
+	// fromReview is set when the command is built from the state of review rather than typed.
+	fromReview bool
+}
+
+// parseCommands extracts all the commands in the comment. A command must be at the beginning
+// of a line, and the lines of quotes, code blocks, code spans and html comments are ignored.
+func parseCommands(body string) []command {
+	var r []command
+
+	fence := ""
+	inComment := false
+
+	for _, line := range strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		indent := len(line) - len(trimmed)
+
+		if fence != "" {
+			// the closing fence is made of the same character and is at least as long as the opening one.
+			if f := codeFence(trimmed); indent < 4 && f != "" && f[0] == fence[0] &&
+				len(f) >= len(fence) && strings.TrimSpace(trimmed[len(f):]) == "" {
+				fence = ""
+			}
+
+			continue
+		}
+
+		if inComment {
+			i := strings.Index(line, "-->")
+			if i < 0 {
+				continue
+			}
+
+			inComment = false
+			line = line[i+len("-->"):]
+			trimmed = strings.TrimLeft(line, " ")
+		}
+
+		if f := codeFence(trimmed); indent < 4 && f != "" {
+			fence = f
+
+			continue
+		}
+
+		if indent >= 4 || strings.HasPrefix(trimmed, "\t") || strings.HasPrefix(trimmed, ">") {
+			continue
+		}
+
+		line = regHTMLComment.ReplaceAllString(line, "")
+		if i := strings.Index(line, "<!--"); i >= 0 {
+			inComment = true
+			line = line[:i]
+		}
+
+		line = strings.TrimSpace(stripCodeSpans(line))
+
+		if m := regCommandLine.FindStringSubmatch(line); m != nil {
+			r = append(r, command{
+				name: strings.ToLower(m[1]),
+				args: strings.Fields(m[2]),
+			})
+		}
+	}
+
+	return r
+}
+
+// codeFence returns the run of backticks or tildes at the beginning of line
+// if it is long enough to be a code fence.
+func codeFence(line string) string {
+	if line == "" || (line[0] != '`' && line[0] != '~') {
+		return ""
+	}
+
+	n := len(line) - len(strings.TrimLeft(line, line[:1]))
+	if n < 3 {
+		return ""
+	}
+
+	// the info string of a backtick fence can't contain backticks, such line is a code span.
+	if line[0] == '`' && strings.Contains(line[n:], "`") {
+		return ""
+	}
+
+	return line[:n]
+}
+
+// stripCodeSpans removes the code spans in the line. A code span begins with a run of backticks
+// and ends with the next run of the same length. A run which is not closed is kept as it is.
+func stripCodeSpans(line string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(line); {
+		if line[i] != '`' {
+			b.WriteByte(line[i])
+			i++
+
+			continue
+		}
+
+		n := backtickRun(line, i)
+
+		end := -1
+		for j := i + n; j < len(line); {
+			if line[j] != '`' {
+				j++
+
+				continue
+			}
+
+			m := backtickRun(line, j)
+			if m == n {
+				end = j + m
+
+				break
+			}
+
+			j += m
+		}
+
+		if end < 0 {
+			b.WriteString(line[i : i+n])
+			i += n
+		} else {
+			i = end
+		}
+	}
+
+	return b.String()
+}
+
+// backtickRun returns the length of the run of backticks beginning at i.
+func backtickRun(line string, i int) int {
+	n := 0
+	for i+n < len(line) && line[i+n] == '`' {
+		n++
+	}
+
+	return n
+}
+
+// commandMatcher matches the comments which contain the command with exactly the arguments.
+type commandMatcher struct {
+	name string
+	args []string
+}
+
+// newCommandMatcher creates the matcher of command, such as "/lgtm cancel".
+func newCommandMatcher(s string) commandMatcher {
+	v := strings.Fields(strings.TrimPrefix(s, "/"))
+
+	return commandMatcher{
+		name: strings.ToLower(v[0]),
+		args: v[1:],
+	}
+}
+
+func (m commandMatcher) match(c command) bool {
+	if c.name != m.name || len(c.args) != len(m.args) {
+		return false
+	}
+
+	for i := range m.args {
+		if !strings.EqualFold(c.args[i], m.args[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// MatchString tells whether the comment contains the command.
+func (m commandMatcher) MatchString(body string) bool {
+	for _, c := range parseCommands(body) {
+		if m.match(c) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCommands(t *testing.T) {
+	cases := []struct {
+		name string
+		body string
+		want []command
+	}{
+		{
+			name: "commands on separate lines",
+			body: "/lgtm\r\n/approve cancel\n/assign @a  @b",
+			want: []command{
+				{name: "lgtm", args: []string{}},
+				{name: "approve", args: []string{"cancel"}},
+				{name: "assign", args: []string{"@a", "@b"}},
+			},
+		},
+		{
+			name: "command not at the beginning of line",
+			body: "please /lgtm\n  /approve",
+			want: []command{{name: "approve", args: []string{}}},
+		},
+		{
+			name: "upper case name",
+			body: "/LGTM",
+			want: []command{{name: "lgtm", args: []string{}}},
+		},
+		{
+			name: "quote",
+			body: "> /lgtm\n>/approve\n/hold",
+			want: []command{{name: "hold", args: []string{}}},
+		},
+		{
+			name: "code span",
+			body: "`/lgtm`\n`/approve` /hold\n/check-pr `cancel`",
+			want: []command{
+				{name: "hold", args: []string{}},
+				{name: "check-pr", args: []string{}},
+			},
+		},
+		{
+			name: "code span of several backticks",
+			body: "``/lgtm``\n``a`/approve`b``\n``` /hold ```\n/unhold",
+			want: []command{{name: "unhold", args: []string{}}},
+		},
+		{
+			name: "unclosed backticks",
+			body: "/hold ``cancel`",
+			want: []command{{name: "hold", args: []string{"``cancel`"}}},
+		},
+		{
+			name: "backticks in one line are not a fence",
+			body: "```/lgtm```\n/approve\n/hold",
+			want: []command{
+				{name: "approve", args: []string{}},
+				{name: "hold", args: []string{}},
+			},
+		},
+		{
+			name: "indented code",
+			body: "    /lgtm\n\t/approve\n   /hold",
+			want: []command{{name: "hold", args: []string{}}},
+		},
+		{
+			name: "html comment in one line",
+			body: "<!-- /lgtm -->\n<!-- note --> /approve",
+			want: []command{{name: "approve", args: []string{}}},
+		},
+		{
+			name: "html comment in lines",
+			body: "<!--\n/lgtm\n-->\n/approve\n/hold <!-- /unhold\n-->",
+			want: []command{
+				{name: "approve", args: []string{}},
+				{name: "hold", args: []string{}},
+			},
+		},
+		{
+			name: "fenced code",
+			body: "```\n/lgtm\n```\n~~~go\n/approve\n~~~\n/hold",
+			want: []command{{name: "hold", args: []string{}}},
+		},
+		{
+			name: "longer fence wrapping a shorter one",
+			body: "````\n```\n/lgtm\n````\n/approve",
+			want: []command{{name: "approve", args: []string{}}},
+		},
+		{
+			name: "fence closed only by the same character",
+			body: "~~~\n```\n/lgtm\n~~~\n/approve",
+			want: []command{{name: "approve", args: []string{}}},
+		},
+		{
+			name: "fence with text after is not closing",
+			body: "```\n``` /lgtm\n/approve\n```\n/hold",
+			want: []command{{name: "hold", args: []string{}}},
+		},
+		{
+			name: "unclosed fence",
+			body: "```\n/lgtm",
+			want: nil,
+		},
+	}
+
+	for _, c := range cases {
+		if got := parseCommands(c.body); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %#v, want %#v", c.name, got, c.want)
+		}
+	}
+}
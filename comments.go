@@ -0,0 +1,97 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	sdk "github.com/google/go-github/v36/github"
+	gc "github.com/opensourceways/robot-github-lib/client"
+)
+
+// prComment is a comment, a review or an inline review comment of pr with the commands in it.
+type prComment struct {
+	login     string
+	cmds      []command
+	createdAt time.Time
+	edited    bool
+}
+
+func (c *prComment) has(m commandMatcher) bool {
+	for _, cmd := range c.cmds {
+		if m.match(cmd) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// listPRComments gathers the comments, the reviews and the inline review comments of pr
+// in the order of creation, so that the commands are counted wherever dispatchCommands
+// handles them. An approved review works as /lgtm, and a dismissed one does not.
+func listPRComments(cli iClient, pr gc.PRInfo) ([]prComment, error) {
+	comments, err := cli.ListIssueComments(pr)
+	if err != nil {
+		return nil, err
+	}
+
+	reviews, err := cli.ListReviews(pr)
+	if err != nil {
+		return nil, err
+	}
+
+	reviewComments, err := cli.ListReviewComments(pr)
+	if err != nil {
+		return nil, err
+	}
+
+	r := newPRCommentsOfIssue(comments)
+
+	for _, v := range reviews {
+		if v.SubmittedAt == nil {
+			continue
+		}
+
+		cmds := parseCommands(v.GetBody())
+		if strings.ToLower(v.GetState()) == reviewStateApproved {
+			cmds = append([]command{{name: cmdAddLgtm.name}}, cmds...)
+		}
+
+		r = append(r, prComment{
+			login:     v.GetUser().GetLogin(),
+			cmds:      cmds,
+			createdAt: v.GetSubmittedAt(),
+		})
+	}
+
+	for _, c := range reviewComments {
+		r = append(r, prComment{
+			login:     c.GetUser().GetLogin(),
+			cmds:      parseCommands(c.GetBody()),
+			createdAt: c.GetCreatedAt(),
+			edited:    !c.GetUpdatedAt().Equal(c.GetCreatedAt()),
+		})
+	}
+
+	sort.SliceStable(r, func(i, j int) bool {
+		return r[i].createdAt.Before(r[j].createdAt)
+	})
+
+	return r, nil
+}
+
+// newPRCommentsOfIssue converts the issue comments of pr to prComment.
+func newPRCommentsOfIssue(comments []*sdk.IssueComment) []prComment {
+	r := make([]prComment, 0, len(comments))
+	for _, c := range comments {
+		r = append(r, prComment{
+			login:     c.GetUser().GetLogin(),
+			cmds:      parseCommands(c.GetBody()),
+			createdAt: c.GetCreatedAt(),
+			edited:    !c.GetUpdatedAt().Equal(c.GetCreatedAt()),
+		})
+	}
+
+	return r
+}
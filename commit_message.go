@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+
+	gc "github.com/opensourceways/robot-github-lib/client"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	defaultCommitMessageTemplate = "\n" +
+		"{{if or .Reviewers .Approvers .Ackers}}" +
+		"From: @{{.Author}} \n" +
+		"Reviewed-by: {{logins .Reviewers}} \n" +
+		"Signed-off-by: {{logins .Approvers}} \n" +
+		"{{end}}"
+
+	trailerCommitMessageTemplate = "\n" +
+		"Merge Pull Request from: @{{.Author}} \n \n" +
+		"{{.Body}} \n \n" +
+		"Link:{{.URL}} \n" +
+		"{{if or .Reviewers .Approvers .Ackers}}\n" +
+		"{{range .Reviewers}}{{if .Email}}Reviewed-by: {{.Name}} <{{.Email}}> \n{{end}}{{end}}" +
+		"{{range .Approvers}}{{if .Email}}Signed-off-by: {{.Name}} <{{.Email}}> \n{{end}}{{end}}" +
+		"{{range .Ackers}}{{if .Email}}Acked-by: {{.Name}} <{{.Email}}> \n{{end}}{{end}}" +
+		"{{end}}"
+)
+
+var (
+	commitMessageFuncs = template.FuncMap{
+		"logins": func(users []commitUser) string {
+			v := make([]string, len(users))
+			for i := range users {
+				v[i] = "@" + users[i].Login
+			}
+
+			return strings.Join(v, ", ")
+		},
+	}
+
+	defaultCommitMessageTpl = template.Must(
+		newCommitMessageTemplate("default", defaultCommitMessageTemplate),
+	)
+
+	trailerCommitMessageTpl = template.Must(
+		newCommitMessageTemplate("trailer", trailerCommitMessageTemplate),
+	)
+)
+
+func newCommitMessageTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(commitMessageFuncs).Parse(text)
+}
+
+// commitMessageData is the data which can be used in the template of commit message.
+type commitMessageData struct {
+	Title       string
+	Body        string
+	Number      int
+	URL         string
+	Author      string
+	MergeMethod string
+
+	// Reviewers are the ones who commented /lgtm.
+	Reviewers []commitUser
+	// Approvers are the ones who commented /approve.
+	Approvers []commitUser
+	// Ackers are the ones who commented /ack.
+	Ackers []commitUser
+
+	// Commits are the commits of PR. It is only available in the squash template.
+	Commits []commitInfo
+}
+
+// commitUser is a user who reviewed the PR. The Name and Email are only
+// available when they can be found in the identity file.
+type commitUser struct {
+	Login string
+	Name  string
+	Email string
+}
+
+type commitInfo struct {
+	SHA     string
+	Message string
+	Author  string
+	Email   string
+}
+
+func (m *mergeHelper) commitMessageTemplate(method string) *template.Template {
+	if method == string(mergeMethodSquash) && m.cfg.squashCommitMessageTpl != nil {
+		return m.cfg.squashCommitMessageTpl
+	}
+
+	if m.cfg.commitMessageTpl != nil {
+		return m.cfg.commitMessageTpl
+	}
+
+	if m.cfg.trailerProfile != nil {
+		return trailerCommitMessageTpl
+	}
+
+	return defaultCommitMessageTpl
+}
+
+func (m *mergeHelper) genCommitMessage(method string) (string, error) {
+	data, err := m.genCommitMessageData(method)
+	if err != nil {
+		return "", err
+	}
+
+	buf := new(bytes.Buffer)
+	if err := m.commitMessageTemplate(method).Execute(buf, data); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+func (m *mergeHelper) genCommitMessageData(method string) (*commitMessageData, error) {
+	reviewers, approvers, ackers := m.getReviewers()
+
+	identities := map[string]commitUser{}
+	body := m.pr.GetBody()
+
+	if p := m.cfg.trailerProfile; p != nil {
+		if p.IdentityFile != nil {
+			identities = m.getIdentities(p.IdentityFile)
+		}
+
+		if p.SyncBot != "" && m.pr.GetUser().GetLogin() == p.SyncBot {
+			body = m.genSyncBotBody()
+		}
+	}
+
+	toUsers := func(logins sets.String) []commitUser {
+		v := make([]commitUser, 0, logins.Len())
+		for _, login := range logins.List() {
+			if u, ok := identities[login]; ok {
+				v = append(v, u)
+			} else {
+				v = append(v, commitUser{Login: login})
+			}
+		}
+
+		return v
+	}
+
+	data := &commitMessageData{
+		Title:       m.pr.GetTitle(),
+		Body:        body,
+		Number:      m.pr.GetNumber(),
+		URL:         m.pr.GetHTMLURL(),
+		Author:      m.pr.GetUser().GetLogin(),
+		MergeMethod: method,
+		Reviewers:   toUsers(reviewers),
+		Approvers:   toUsers(approvers),
+		Ackers:      toUsers(ackers),
+	}
+
+	if method == string(mergeMethodSquash) && m.cfg.squashCommitMessageTpl != nil {
+		commits, err := m.cli.GetPRCommits(gc.PRInfo{Org: m.org, Repo: m.repo, Number: m.pr.GetNumber()})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range commits {
+			data.Commits = append(data.Commits, commitInfo{
+				SHA:     c.GetSHA(),
+				Message: c.GetCommit().GetMessage(),
+				Author:  c.GetCommit().GetAuthor().GetName(),
+				Email:   c.GetCommit().GetAuthor().GetEmail(),
+			})
+		}
+	}
+
+	return data, nil
+}
+
+// getReviewers returns the ones who used /lgtm, /approve and /ack on the PR, including
+// the approved reviews. The edited comments are counted only when the trailer profile allows.
+func (m *mergeHelper) getReviewers() (sets.String, sets.String, sets.String) {
+	reviewers := sets.NewString()
+	signers := sets.NewString()
+	ackers := sets.NewString()
+
+	comments, err := listPRComments(m.cli, gc.PRInfo{Org: m.org, Repo: m.repo, Number: m.pr.GetNumber()})
+	if err != nil || len(comments) == 0 {
+		return reviewers, signers, ackers
+	}
+
+	author := m.pr.GetUser().GetLogin()
+	p := m.cfg.trailerProfile
+	countEdited := p != nil && p.CountEditedComments
+
+	for i := range comments {
+		c := &comments[i]
+		if c.login == author || (c.edited && !countEdited) {
+			continue
+		}
+
+		if c.has(cmdAddLgtm) {
+			reviewers.Insert(c.login)
+		}
+
+		if c.has(cmdAddApprove) {
+			signers.Insert(c.login)
+		}
+
+		if p != nil && p.EnableAck && c.has(cmdAck) {
+			ackers.Insert(c.login)
+		}
+	}
+
+	return reviewers, signers, ackers
+}
@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"text/template"
 
 	"github.com/opensourceways/server-common-lib/config"
+	"k8s.io/apimachinery/pkg/util/sets"
 )
 
 type pullRequestMergeMethod string
@@ -17,6 +19,13 @@ const (
 
 type configuration struct {
 	ConfigItems []botConfig `json:"config_items,omitempty"`
+
+	// TrailerProfiles are the profiles of trailers in commit message which can be
+	// selected by config items. The builtin profile named kernel is always available.
+	TrailerProfiles []trailerProfile `json:"trailer_profiles,omitempty"`
+
+	// profiles are the builtin and the configured trailer profiles by name.
+	profiles map[string]trailerProfile
 }
 
 func (c *configuration) configFor(org, repo string) *botConfig {
@@ -31,11 +40,28 @@ func (c *configuration) configFor(org, repo string) *botConfig {
 		v[i] = &items[i]
 	}
 
-	if i := config.Find(org, repo, v); i >= 0 {
-		return &items[i]
+	i := config.Find(org, repo, v)
+	if i < 0 {
+		return nil
 	}
 
-	return nil
+	item := &items[i]
+
+	// the repository used to have the behavior of kernel profile hard coded,
+	// so it keeps the behavior unless another profile is selected.
+	if item.trailerProfile == nil && org+"/"+repo == builtinTrailerProfileRepo {
+		p, ok := c.profiles[builtinTrailerProfile]
+		if !ok {
+			p = defaultTrailerProfiles()[builtinTrailerProfile]
+		}
+
+		v := *item
+		v.trailerProfile = &p
+
+		return &v
+	}
+
+	return item
 }
 
 func (c *configuration) Validate() error {
@@ -43,11 +69,34 @@ func (c *configuration) Validate() error {
 		return nil
 	}
 
+	profiles := defaultTrailerProfiles()
+	for i := range c.TrailerProfiles {
+		p := &c.TrailerProfiles[i]
+		if err := p.validate(); err != nil {
+			return err
+		}
+
+		profiles[p.Name] = *p
+	}
+
+	c.profiles = profiles
+
 	items := c.ConfigItems
 	for i := range items {
-		if err := items[i].validate(); err != nil {
+		item := &items[i]
+
+		if err := item.validate(); err != nil {
 			return err
 		}
+
+		if name := item.TrailerProfile; name != "" {
+			p, ok := profiles[name]
+			if !ok {
+				return fmt.Errorf("unknown trailer profile: %s", name)
+			}
+
+			item.trailerProfile = &p
+		}
 	}
 
 	return nil
@@ -77,6 +126,16 @@ type botConfig struct {
 	// command. The repository is 'tc' at present.
 	CheckPermissionBasedOnSigOwners bool `json:"check_permission_based_on_sig_owners,omitempty"`
 
+	// ApproveBasedOnOwners means the /approve command is checked with the OWNERS files
+	// in the target branch. Every changed file must be approved by at least one of
+	// the approvers of the nearest OWNERS file and its parents.
+	ApproveBasedOnOwners bool `json:"approve_based_on_owners,omitempty"`
+
+	// ScopeAwareInvalidation means the lgtm and approved labels are kept after new commits
+	// are pushed, if the files changed by the push are out of the scope of their reviewers
+	// which is decided by the OWNERS files and the owners of sigs.
+	ScopeAwareInvalidation bool `json:"scope_aware_invalidation,omitempty"`
+
 	// SigsDir is the directory of Sig. It must be set when CheckPermissionBasedOnSigOwners is true.
 	SigsDir   string        `json:"sigs_dir,omitempty"`
 	regSigDir regexp.Regexp `json:"-"`
@@ -88,6 +147,20 @@ type botConfig struct {
 	// MissingLabelsForMerge specifies the ones which a PR must not have to be merged.
 	MissingLabelsForMerge []string `json:"missing_labels_for_merge,omitempty"`
 
+	// LegalLabelActors specifies who can add the labels which are required to merge PR.
+	// The labels which are not matched by any of them can only be added by openeuler-ci-bot.
+	LegalLabelActors []labelActors `json:"legal_label_actors,omitempty"`
+
+	// HoldUsers specifies who can use /hold and /unhold. Valid options are author,
+	// collaborators and sig_owners. The default value is author and collaborators.
+	HoldUsers []string `json:"hold_users,omitempty"`
+
+	// CommandPermissions specifies who can use each command and who can't use any command.
+	CommandPermissions commandPermissions `json:"command_permissions,omitempty"`
+
+	// RateLimits limits how often each user and each pr can use the commands.
+	RateLimits rateLimits `json:"rate_limits,omitempty"`
+
 	// MergeMethod is the method to merge PR.
 	// The default method of merge. Valid options are squash and merge.
 	MergeMethod pullRequestMergeMethod `json:"merge_method,omitempty"`
@@ -95,8 +168,34 @@ type botConfig struct {
 	// UnableCheckingReviewerForPR is a switch used to check whether the pr has been set reviewers when it is open.
 	UnableCheckingReviewerForPR bool `json:"unable_checking_reviewer_for_pr,omitempty"`
 
+	// ReviewerAssignment decides how to deal with the pr which has not been set reviewers when it is open.
+	ReviewerAssignment reviewerAssignment `json:"reviewer_assignment,omitempty"`
+
 	// FreezeFile is the freeze branch of community
 	FreezeFile []freezeFile `json:"freeze_file,omitempty"`
+
+	// FreezePrecedence decides which entry is used when several entries of freeze files
+	// match the target branch. Valid options are most_specific and any_frozen.
+	// The default one is most_specific.
+	FreezePrecedence string `json:"freeze_precedence,omitempty"`
+
+	// TrailerProfile is the name of trailer profile used by the repository.
+	// It enables the /ack command and the trailers with the name and email of reviewers.
+	TrailerProfile string          `json:"trailer_profile,omitempty"`
+	trailerProfile *trailerProfile `json:"-"`
+
+	// CommitMessageTemplate is the go template of commit message when merging PR.
+	// The fields of commitMessageData can be used in it, such as .Title, .Body,
+	// .Author, .URL, .Reviewers, .Approvers, .Ackers and .MergeMethod.
+	// The default message lists the author, reviewers and approvers of PR, or the trailers
+	// of reviewers when the trailer profile is set.
+	CommitMessageTemplate string             `json:"commit_message_template,omitempty"`
+	commitMessageTpl      *template.Template `json:"-"`
+
+	// SquashCommitMessageTemplate is used instead of CommitMessageTemplate when PR
+	// is merged by squash. It can use the commits of PR by .Commits additionally.
+	SquashCommitMessageTemplate string             `json:"squash_commit_message_template,omitempty"`
+	squashCommitMessageTpl      *template.Template `json:"-"`
 }
 
 func (c *botConfig) setDefault() {
@@ -107,6 +206,20 @@ func (c *botConfig) setDefault() {
 	if c.MergeMethod == "" {
 		c.MergeMethod = mergeMethodeMerge
 	}
+
+	if c.FreezePrecedence == "" {
+		c.FreezePrecedence = freezePrecedenceMostSpecific
+	}
+
+	c.ReviewerAssignment.setDefault()
+
+	if c.CommandPermissions.Community == nil {
+		c.CommandPermissions.Community = c.ReviewerAssignment.Community
+	}
+
+	if len(c.HoldUsers) == 0 {
+		c.HoldUsers = []string{userKindAuthor, userKindCollaborators}
+	}
 }
 
 func (c *botConfig) validate() error {
@@ -130,39 +243,395 @@ func (c *botConfig) validate() error {
 		c.regSigDir = *v
 	}
 
+	for _, v := range c.HoldUsers {
+		switch v {
+		case userKindAuthor, userKindCollaborators:
+		case userKindSigOwners:
+			if !c.CheckPermissionBasedOnSigOwners {
+				return fmt.Errorf("hold_users of sig_owners needs check_permission_based_on_sig_owners")
+			}
+		default:
+			return fmt.Errorf("unsupported hold user:%s", v)
+		}
+	}
+
+	if err := c.ReviewerAssignment.validate(); err != nil {
+		return err
+	}
+
+	if err := c.CommandPermissions.validate(c.CheckPermissionBasedOnSigOwners); err != nil {
+		return err
+	}
+
+	if err := c.RateLimits.validate(); err != nil {
+		return err
+	}
+
+	if c.CommitMessageTemplate != "" {
+		v, err := newCommitMessageTemplate("commit_message", c.CommitMessageTemplate)
+		if err != nil {
+			return fmt.Errorf("invalid commit_message_template: %v", err)
+		}
+
+		c.commitMessageTpl = v
+	}
+
+	if c.SquashCommitMessageTemplate != "" {
+		v, err := newCommitMessageTemplate("squash_commit_message", c.SquashCommitMessageTemplate)
+		if err != nil {
+			return fmt.Errorf("invalid squash_commit_message_template: %v", err)
+		}
+
+		c.squashCommitMessageTpl = v
+	}
+
+	for i := range c.LegalLabelActors {
+		if err := c.LegalLabelActors[i].validate(); err != nil {
+			return err
+		}
+	}
+
+	if p := c.FreezePrecedence; p != freezePrecedenceMostSpecific && p != freezePrecedenceAnyFrozen {
+		return fmt.Errorf("unsupported freeze precedence:%s", p)
+	}
+
 	for _, v := range c.FreezeFile {
-		return v.validate()
+		if err := v.validate(); err != nil {
+			return err
+		}
 	}
 
 	return c.RepoFilter.Validate()
 }
 
+// legalActorsOf returns the actors who can add the label. The actors of the label
+// itself take precedence over the ones of the longest matched prefix.
+func (c *botConfig) legalActorsOf(label string) *labelActors {
+	var r *labelActors
+
+	for i := range c.LegalLabelActors {
+		item := &c.LegalLabelActors[i]
+
+		if item.Label != "" {
+			if item.Label == label {
+				return item
+			}
+
+			continue
+		}
+
+		if strings.HasPrefix(label, item.LabelPrefix) &&
+			(r == nil || len(item.LabelPrefix) > len(r.LabelPrefix)) {
+			r = item
+		}
+	}
+
+	if r == nil {
+		return &defaultLabelActors
+	}
+
+	return r
+}
+
+var defaultLabelActors = labelActors{Actors: []string{legalLabelsAddedBy}}
+
+type labelActors struct {
+	// Label is the name of label. Only one of Label and LabelPrefix can be set.
+	Label string `json:"label,omitempty"`
+
+	// LabelPrefix matches all the labels which start with it.
+	LabelPrefix string `json:"label_prefix,omitempty"`
+
+	// Actors are the logins of users who can add the label.
+	Actors []string `json:"actors,omitempty"`
+
+	// Apps are the slugs of github apps which can add the label.
+	Apps []string `json:"apps,omitempty"`
+}
+
+func (a *labelActors) validate() error {
+	if (a.Label == "") == (a.LabelPrefix == "") {
+		return fmt.Errorf("only one of label and label_prefix should be set for legal label actors")
+	}
+
+	if len(a.Actors) == 0 && len(a.Apps) == 0 {
+		return fmt.Errorf("missing actors and apps of label: %s%s", a.Label, a.LabelPrefix)
+	}
+
+	return nil
+}
+
+// isLegal tells whether the user who is the actor of timeline event can add the label.
+// A github app acts as the bot user whose login is its slug with suffix [bot].
+func (a *labelActors) isLegal(login string) bool {
+	for _, v := range a.Actors {
+		if strings.EqualFold(v, login) {
+			return true
+		}
+	}
+
+	for _, v := range a.Apps {
+		if strings.EqualFold(v+"[bot]", login) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type reviewerAssignment struct {
+	// Mode is one of notify, suggest and assign. The notify only reminds the author to set
+	// reviewers. The suggest posts the candidate reviewers with a /assign line, and the assign
+	// assigns them directly. The default value is notify.
+	Mode string `json:"mode,omitempty"`
+
+	// Count is the number of reviewers to suggest or assign. The default value is 2.
+	Count int `json:"count,omitempty"`
+
+	// Community is the repository which holds the sig-info.yaml of all the sigs. The maintainers
+	// and committers of the sig which the repository belongs to are the candidates too.
+	Community *repoBranch `json:"community,omitempty"`
+}
+
+func (r *reviewerAssignment) setDefault() {
+	if r.Mode == "" {
+		r.Mode = reviewerModeNotify
+	}
+
+	if r.Count == 0 {
+		r.Count = 2
+	}
+}
+
+func (r *reviewerAssignment) validate() error {
+	if m := r.Mode; m != reviewerModeNotify && m != reviewerModeSuggest && m != reviewerModeAssign {
+		return fmt.Errorf("unsupported mode of reviewer assignment:%s", m)
+	}
+
+	if r.Count < 0 {
+		return fmt.Errorf("count of reviewer assignment can't be negative")
+	}
+
+	if r.Community != nil {
+		return r.Community.validate()
+	}
+
+	return nil
+}
+
+type commandPermissions struct {
+	// Commands specifies who can use the commands. The commands which are not
+	// specified can be used by their default users which are listed by /help.
+	Commands []commandPermission `json:"commands,omitempty"`
+
+	// DeniedUsers can't use any command, such as the accounts of other robots.
+	DeniedUsers []string `json:"denied_users,omitempty"`
+
+	// DenyBots means the accounts of github apps and other bots can't use any command.
+	DenyBots bool `json:"deny_bots,omitempty"`
+
+	// Community is the repository which holds the sig-info.yaml of all the sigs. It decides the
+	// sig_maintainers and sig_committers of repository. The one of reviewer_assignment is used
+	// if it is not set.
+	Community *repoBranch `json:"community,omitempty"`
+}
+
+func (c *commandPermissions) validate(sigOwnersEnabled bool) error {
+	names := sets.NewString()
+	for _, v := range newCommandSpecs() {
+		names.Insert(v.name)
+	}
+
+	for i := range c.Commands {
+		item := &c.Commands[i]
+
+		if !names.Has(item.Command) {
+			return fmt.Errorf("unknown command of permission:%s", item.Command)
+		}
+
+		if len(item.Roles) == 0 && len(item.Users) == 0 && len(item.Teams) == 0 {
+			return fmt.Errorf("missing roles, users or teams of command:%s", item.Command)
+		}
+
+		for _, r := range item.Roles {
+			if _, ok := userKindDescs[r]; !ok {
+				return fmt.Errorf("unsupported role of command %s:%s", item.Command, r)
+			}
+
+			if r == userKindSigOwners && !sigOwnersEnabled {
+				return fmt.Errorf("role of sig_owners needs check_permission_based_on_sig_owners")
+			}
+
+			if (r == userKindSigMaintainers || r == userKindSigCommitters) && c.Community == nil {
+				return fmt.Errorf("role of %s needs the community of command permissions", r)
+			}
+		}
+	}
+
+	if c.Community != nil {
+		return c.Community.validate()
+	}
+
+	return nil
+}
+
+// get returns the permission of command, it is nil if the command is not specified.
+func (c *commandPermissions) get(name string) *commandPermission {
+	for i := range c.Commands {
+		if c.Commands[i].Command == name {
+			return &c.Commands[i]
+		}
+	}
+
+	return nil
+}
+
+func (c *commandPermissions) isDenied(login string, isBot bool) bool {
+	if isBot && c.DenyBots {
+		return true
+	}
+
+	for _, v := range c.DeniedUsers {
+		if strings.EqualFold(v, login) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type commandPermission struct {
+	// Command is the name of command without the leading slash, such as lgtm.
+	Command string `json:"command" required:"true"`
+
+	// Roles are the kinds of users who can use the command. Valid options are anyone, author,
+	// collaborators, admins, sig_owners, sig_maintainers, sig_committers, approvers and freeze_owners.
+	Roles []string `json:"roles,omitempty"`
+
+	// Users are the logins of users who can use the command.
+	Users []string `json:"users,omitempty"`
+
+	// Teams are the teams whose members can use the command, such as openeuler/tc.
+	// The organization of repository is used if it is omitted.
+	Teams []string `json:"teams,omitempty"`
+}
+
+type rateLimits struct {
+	// Commands are the rate limits of commands. The one of command * applies to
+	// the commands which are not listed.
+	Commands []commandRateLimit `json:"commands,omitempty"`
+
+	// Acknowledge means the robot comments once when a command is limited,
+	// otherwise the limited commands are dropped silently.
+	Acknowledge bool `json:"acknowledge,omitempty"`
+}
+
+func (r *rateLimits) validate() error {
+	names := sets.NewString(rateLimitAnyCommand)
+	for _, v := range newCommandSpecs() {
+		names.Insert(v.name)
+	}
+
+	for i := range r.Commands {
+		item := &r.Commands[i]
+
+		if !names.Has(item.Command) {
+			return fmt.Errorf("unknown command of rate limit:%s", item.Command)
+		}
+
+		if item.PerUser == nil && item.PerPR == nil {
+			return fmt.Errorf("missing per_user or per_pr of rate limit:%s", item.Command)
+		}
+
+		for _, l := range []*rateLimit{item.PerUser, item.PerPR} {
+			if l != nil && (l.Burst <= 0 || l.Interval <= 0) {
+				return fmt.Errorf("burst and interval of rate limit must be positive:%s", item.Command)
+			}
+		}
+	}
+
+	return nil
+}
+
+// get returns the rate limit of command, it is nil if the command is not limited.
+func (r *rateLimits) get(name string) *commandRateLimit {
+	var fallback *commandRateLimit
+
+	for i := range r.Commands {
+		switch r.Commands[i].Command {
+		case name:
+			return &r.Commands[i]
+		case rateLimitAnyCommand:
+			fallback = &r.Commands[i]
+		}
+	}
+
+	return fallback
+}
+
+type commandRateLimit struct {
+	// Command is the name of command without the leading slash, or * for the others.
+	Command string `json:"command" required:"true"`
+
+	// PerUser is the token bucket of each user in the repository.
+	PerUser *rateLimit `json:"per_user,omitempty"`
+
+	// PerPR is the token bucket of each pr.
+	PerPR *rateLimit `json:"per_pr,omitempty"`
+}
+
+type rateLimit struct {
+	// Burst is the capacity of bucket, which is how many times the command can be used at once.
+	Burst int `json:"burst" required:"true"`
+
+	// Interval is the seconds to refill a token.
+	Interval int `json:"interval" required:"true"`
+}
+
+type repoBranch struct {
+	Owner  string `json:"owner" required:"true"`
+	Repo   string `json:"repo" required:"true"`
+	Branch string `json:"branch" required:"true"`
+}
+
+func (r *repoBranch) validate() error {
+	if r.Owner == "" || r.Repo == "" || r.Branch == "" {
+		return fmt.Errorf("missing owner, repo or branch of repository")
+	}
+
+	return nil
+}
+
 type freezeFile struct {
+	repoFile
+}
+
+type repoFile struct {
 	Owner  string `json:"owner" required:"true"`
 	Repo   string `json:"repo" required:"true"`
 	Branch string `json:"branch" required:"true"`
 	Path   string `json:"path" required:"true"`
 }
 
-func (f freezeFile) toString() string {
+func (f repoFile) toString() string {
 	return fmt.Sprintf("%s/%s/%s:%s", f.Owner, f.Repo, f.Branch, f.Path)
 }
 
-func (f freezeFile) validate() error {
+func (f repoFile) validate() error {
 	if f.Owner == "" {
-		return fmt.Errorf("missing owner of freeze file")
+		return fmt.Errorf("missing owner of file")
 	}
 
 	if f.Repo == "" {
-		return fmt.Errorf("missing repo of freeze file")
+		return fmt.Errorf("missing repo of file")
 	}
 
 	if f.Branch == "" {
-		return fmt.Errorf("missing branch of freeze file")
+		return fmt.Errorf("missing branch of file")
 	}
 
 	if f.Path == "" {
-		return fmt.Errorf("missing path of freeze file")
+		return fmt.Errorf("missing path of file")
 	}
 
 	return nil
@@ -1,31 +1,225 @@
 package main
 
-import "k8s.io/apimachinery/pkg/util/sets"
+import (
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	gc "github.com/opensourceways/robot-github-lib/client"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	oneDay  = 24 * time.Hour
+	oneWeek = 7 * oneDay
+
+	freezePrecedenceMostSpecific = "most_specific"
+	freezePrecedenceAnyFrozen    = "any_frozen"
+
+	// maxFreezeSpans limits the number of the consecutive windows when finding
+	// the time the freeze lifts, in case the windows cover all the time.
+	maxFreezeSpans = 100
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
 
 type freezeContent struct {
 	Release []freezeItem `json:"release"`
 }
 
-func (fc freezeContent) getFreezeItem(org, branch string) *freezeItem {
+// getFreezeItems returns all the entries which match the branch of repository.
+func (fc freezeContent) getFreezeItems(org, repo, branch string) []*freezeItem {
+	var r []*freezeItem
+
 	for i := range fc.Release {
-		v := &fc.Release[i]
-		if v.Branch == branch && v.hasOrg(org) {
-			return v
+		if v := &fc.Release[i]; v.match(org, repo, branch) {
+			r = append(r, v)
 		}
 	}
 
-	return nil
+	return r
 }
 
 type freezeItem struct {
+	// Branch is the name of branch, or a glob pattern such as openEuler-24.03-LTS*.
 	Branch    string   `json:"branch"`
 	Community []string `json:"community"`
 	Frozen    bool     `json:"frozen"`
 	Owner     []string `json:"owner"`
+
+	// Repos limits the freeze to the repositories in the format of org/repo.
+	// Community is ignored when it is set.
+	Repos []string `json:"repos,omitempty"`
+
+	// Start and End are the RFC3339 timestamps of a scheduled freeze.
+	// The freeze has no beginning or ending when the corresponding one is empty.
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+
+	// Windows are the recurring freezes, such as the weekend freeze.
+	Windows []freezeWindow `json:"windows,omitempty"`
+
+	// Timezone is the IANA name of the time zone of Windows, the default one is UTC.
+	Timezone string `json:"timezone,omitempty"`
 }
 
-func (fi *freezeItem) isFrozen() bool {
-	return fi.Frozen
+// freezeWindow is a recurring freeze. Start and End are in the format of 'Sat 00:00'
+// for the weekly window, or '22:00' for the daily window.
+type freezeWindow struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// frozenAt tells whether the branch is frozen at the time t, and the time when
+// the freeze lifts. The time is zero if the freeze has no ending.
+// Frozen means the branch is frozen until it is unset, regardless of the windows.
+func (fi *freezeItem) frozenAt(t time.Time) (bool, time.Time, error) {
+	if fi.Frozen {
+		return true, time.Time{}, nil
+	}
+
+	spans, err := fi.parseSpans()
+	if err != nil {
+		return false, time.Time{}, err
+	}
+
+	frozen := false
+	until := t
+
+	for i := 0; i < maxFreezeSpans; i++ {
+		var end time.Time
+
+		found := false
+		for _, s := range spans {
+			ok, e := s.active(until)
+			if !ok {
+				continue
+			}
+
+			if e.IsZero() {
+				return true, time.Time{}, nil
+			}
+
+			if !found || e.After(end) {
+				end = e
+			}
+
+			found = true
+		}
+
+		if !found {
+			if !frozen {
+				return false, time.Time{}, nil
+			}
+
+			return true, until, nil
+		}
+
+		frozen = true
+		until = end
+	}
+
+	return true, time.Time{}, nil
+}
+
+func (fi *freezeItem) parseSpans() ([]freezeSpan, error) {
+	var r []freezeSpan
+
+	if fi.Start != "" || fi.End != "" {
+		var w fixedWindow
+		var err error
+
+		if fi.Start != "" {
+			if w.start, err = time.Parse(time.RFC3339, fi.Start); err != nil {
+				return nil, fmt.Errorf("invalid start of freeze: %v", err)
+			}
+		}
+
+		if fi.End != "" {
+			if w.end, err = time.Parse(time.RFC3339, fi.End); err != nil {
+				return nil, fmt.Errorf("invalid end of freeze: %v", err)
+			}
+		}
+
+		r = append(r, w)
+	}
+
+	if len(fi.Windows) == 0 {
+		return r, nil
+	}
+
+	loc := time.UTC
+	if fi.Timezone != "" {
+		v, err := time.LoadLocation(fi.Timezone)
+		if err != nil {
+			return nil, err
+		}
+
+		loc = v
+	}
+
+	for i := range fi.Windows {
+		w, err := fi.Windows[i].parse(loc)
+		if err != nil {
+			return nil, err
+		}
+
+		r = append(r, w)
+	}
+
+	return r, nil
+}
+
+func (fi *freezeItem) match(org, repo, branch string) bool {
+	if fi.Branch != branch {
+		if !fi.isGlob() {
+			return false
+		}
+
+		if ok, err := path.Match(fi.Branch, branch); err != nil || !ok {
+			return false
+		}
+	}
+
+	if fi.isRepoScoped() {
+		return sets.NewString(fi.Repos...).Has(org + "/" + repo)
+	}
+
+	return fi.hasOrg(org)
+}
+
+func (fi *freezeItem) isGlob() bool {
+	return strings.ContainsAny(fi.Branch, "*?[")
+}
+
+func (fi *freezeItem) isRepoScoped() bool {
+	return len(fi.Repos) > 0
+}
+
+// moreSpecific tells whether fi is more specific than the other one. The entry scoped
+// to repositories is more specific than the one of orgs, and the exact branch is more
+// specific than the glob pattern, and the longer pattern is more specific than the shorter.
+func (fi *freezeItem) moreSpecific(other *freezeItem) bool {
+	if a, b := fi.isRepoScoped(), other.isRepoScoped(); a != b {
+		return a
+	}
+
+	if a, b := !fi.isGlob(), !other.isGlob(); a != b {
+		return a
+	}
+
+	return len(fi.Branch) > len(other.Branch)
 }
 
 func (fi *freezeItem) hasOrg(org string) bool {
@@ -35,3 +229,261 @@ func (fi *freezeItem) hasOrg(org string) bool {
 func (fi *freezeItem) isOwner(owner string) bool {
 	return sets.NewString(fi.Owner...).Has(owner)
 }
+
+// freezeMatch is a freeze entry which matches the target branch of PR.
+type freezeMatch struct {
+	item *freezeItem
+	file *freezeFile
+
+	// rule is the precedence by which the entry is chosen from all the matched entries.
+	rule    string
+	matched int
+}
+
+func (fm *freezeMatch) desc() string {
+	return fmt.Sprintf(
+		"The freeze entry of branch %s in %s is chosen by the rule %s from %d matched entries.",
+		fm.item.Branch, fm.file.toString(), fm.rule, fm.matched,
+	)
+}
+
+// selectFreeze chooses the entry from the matched ones by the precedence.
+// For the most_specific, the most specific entry wins, and the earlier one wins if they are
+// equally specific. For the any_frozen, the branch is frozen if any of the entries is frozen,
+// and the one which lifts the latest wins, or the most specific one if none is frozen.
+func selectFreeze(matches []freezeMatch, precedence string, now time.Time, log *logrus.Entry) *freezeMatch {
+	if len(matches) == 0 {
+		return nil
+	}
+
+	best := -1
+
+	if precedence == freezePrecedenceAnyFrozen {
+		var bestUntil time.Time
+
+		for i := range matches {
+			frozen, until, err := matches[i].item.frozenAt(now)
+			if err != nil {
+				log.WithError(err).Errorf("check freeze of branch:%s", matches[i].item.Branch)
+
+				continue
+			}
+
+			if !frozen {
+				continue
+			}
+
+			if best < 0 || liftsLater(until, bestUntil) ||
+				(until.Equal(bestUntil) && matches[i].item.moreSpecific(matches[best].item)) {
+				best = i
+				bestUntil = until
+			}
+		}
+	}
+
+	rule := freezePrecedenceAnyFrozen
+	if best < 0 {
+		rule = freezePrecedenceMostSpecific
+
+		for i := range matches {
+			if best < 0 || matches[i].item.moreSpecific(matches[best].item) {
+				best = i
+			}
+		}
+	}
+
+	r := matches[best]
+	r.rule = rule
+	r.matched = len(matches)
+
+	return &r
+}
+
+// liftsLater tells whether the freeze lifting at a lifts later than the one at b.
+// The zero time means the freeze never lifts.
+func liftsLater(a, b time.Time) bool {
+	if b.IsZero() {
+		return false
+	}
+
+	return a.IsZero() || a.After(b)
+}
+
+// freezeSpan is a period during which the branch is frozen.
+type freezeSpan interface {
+	// active tells whether the span covers the time t and the end of it.
+	// The end is zero if the span has no ending.
+	active(t time.Time) (bool, time.Time)
+}
+
+type fixedWindow struct {
+	start time.Time
+	end   time.Time
+}
+
+func (w fixedWindow) active(t time.Time) (bool, time.Time) {
+	if !w.start.IsZero() && t.Before(w.start) {
+		return false, time.Time{}
+	}
+
+	if !w.end.IsZero() && !t.Before(w.end) {
+		return false, time.Time{}
+	}
+
+	return true, w.end
+}
+
+// recurringWindow is a window which repeats every period. The start and end
+// are the offsets from the beginning of the period.
+type recurringWindow struct {
+	start  time.Duration
+	end    time.Duration
+	period time.Duration
+	loc    *time.Location
+}
+
+func (w recurringWindow) offset(t time.Time) time.Duration {
+	lt := t.In(w.loc)
+
+	d := time.Duration(lt.Hour())*time.Hour +
+		time.Duration(lt.Minute())*time.Minute +
+		time.Duration(lt.Second())*time.Second
+
+	if w.period == oneWeek {
+		d += time.Duration(lt.Weekday()) * oneDay
+	}
+
+	return d
+}
+
+func (w recurringWindow) active(t time.Time) (bool, time.Time) {
+	t = t.Truncate(time.Second)
+	pos := w.offset(t)
+
+	switch {
+	case w.start < w.end && pos >= w.start && pos < w.end:
+		return true, t.Add(w.end - pos)
+
+	case w.start > w.end && pos >= w.start:
+		return true, t.Add(w.period - pos + w.end)
+
+	case w.start > w.end && pos < w.end:
+		return true, t.Add(w.end - pos)
+	}
+
+	return false, time.Time{}
+}
+
+func (w *freezeWindow) parse(loc *time.Location) (recurringWindow, error) {
+	start, weekly, err := parseWindowPoint(w.Start)
+	if err != nil {
+		return recurringWindow{}, err
+	}
+
+	end, weekly1, err := parseWindowPoint(w.End)
+	if err != nil {
+		return recurringWindow{}, err
+	}
+
+	if weekly != weekly1 {
+		return recurringWindow{}, fmt.Errorf(
+			"start and end of freeze window should both have or not have weekday: %s - %s", w.Start, w.End,
+		)
+	}
+
+	if start == end {
+		return recurringWindow{}, fmt.Errorf("empty freeze window: %s - %s", w.Start, w.End)
+	}
+
+	period := oneDay
+	if weekly {
+		period = oneWeek
+	}
+
+	return recurringWindow{
+		start:  start,
+		end:    end,
+		period: period,
+		loc:    loc,
+	}, nil
+}
+
+// parseWindowPoint parses the point of freeze window, such as 'Sat 00:00' or '22:00'.
+// It returns the offset of the point from the beginning of week or day.
+func parseWindowPoint(s string) (time.Duration, bool, error) {
+	items := strings.Fields(s)
+
+	var offset time.Duration
+
+	switch len(items) {
+	case 1:
+	case 2:
+		name := strings.ToLower(items[0])
+		if len(name) > 3 {
+			name = name[:3]
+		}
+
+		v, ok := weekdays[name]
+		if !ok {
+			return 0, false, fmt.Errorf("invalid weekday of freeze window: %s", s)
+		}
+
+		offset = time.Duration(v) * oneDay
+
+	default:
+		return 0, false, fmt.Errorf("invalid point of freeze window: %s", s)
+	}
+
+	c, err := time.Parse("15:04", items[len(items)-1])
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid time of freeze window: %s", s)
+	}
+
+	offset += time.Duration(c.Hour())*time.Hour + time.Duration(c.Minute())*time.Minute
+
+	return offset, len(items) == 2, nil
+}
+
+// freezeRetries holds the timers which retry merging the PRs blocked by the scheduled freezes
+// when the freezes lift. It is keyed by the PR, so each PR has one pending retry at most.
+type freezeRetries struct {
+	lock   sync.Mutex
+	timers map[string]*time.Timer
+}
+
+func newFreezeRetries() *freezeRetries {
+	return &freezeRetries{timers: make(map[string]*time.Timer)}
+}
+
+func (r *freezeRetries) add(key string, d time.Duration, f func()) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if t, ok := r.timers[key]; ok {
+		t.Stop()
+	}
+
+	r.timers[key] = time.AfterFunc(d, func() {
+		r.lock.Lock()
+		delete(r.timers, key)
+		r.lock.Unlock()
+
+		f()
+	})
+}
+
+// retryMergeAfterFreeze retries merging the PR after the freeze which blocked it lifts.
+func (bot *robot) retryMergeAfterFreeze(h *mergeHelper, cfg *botConfig, log *logrus.Entry) {
+	if h.frozenUntil.IsZero() {
+		return
+	}
+
+	pr := gc.PRInfo{Org: h.org, Repo: h.repo, Number: h.pr.GetNumber()}
+	trigger := h.trigger
+
+	bot.retries.add(pr.String(), time.Until(h.frozenUntil), func() {
+		if err := bot.tryMerge(pr, trigger, cfg, false, log); err != nil {
+			log.WithError(err).Errorf("retry merging %s after freeze", pr.String())
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+
+	sdk "github.com/google/go-github/v36/github"
+	gc "github.com/opensourceways/robot-github-lib/client"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// freezeExceptionLabel is added when an owner of the frozen branch allows the PR
+	// to be merged during the freeze. It is removed when new commits are pushed.
+	freezeExceptionLabel = "freeze-exception"
+
+	msgNoFreezeOfBranch  = "@%s , the target branch %s of this pr is not declared in any freeze file."
+	msgFreezeExceptionOK = "@%s granted the freeze exception and ***%s*** was added. The pr can be merged during the freeze of branch %s."
+)
+
+var (
+	cmdAddFreezeException    = newCommandMatcher("/freeze-exception")
+	cmdRemoveFreezeException = newCommandMatcher("/freeze-exception cancel")
+)
+
+func (bot *robot) handleFreezeException(ev *commandEvent, cmd command, cfg *botConfig, log *logrus.Entry) error {
+	add := cmdAddFreezeException.match(cmd)
+	remove := cmdRemoveFreezeException.match(cmd)
+	if !add && !remove {
+		return nil
+	}
+
+	pr, commenter := ev.pr, ev.commenter
+
+	if remove {
+		return bot.cli.RemovePRLabel(pr, freezeExceptionLabel)
+	}
+
+	_, branch, err := bot.getFreezeOfPR(pr, cfg, log)
+	if err != nil {
+		return err
+	}
+
+	if err := bot.createLabelIfNeed(pr.Org, pr.Repo, freezeExceptionLabel); err != nil {
+		log.WithError(err).Errorf("create repo label: %s", freezeExceptionLabel)
+	}
+
+	if err := bot.cli.AddPRLabel(pr, freezeExceptionLabel); err != nil {
+		return err
+	}
+
+	if err := bot.cli.CreatePRComment(pr, fmt.Sprintf(
+		msgFreezeExceptionOK, commenter, freezeExceptionLabel, branch,
+	)); err != nil {
+		log.WithError(err).Error("comment on freeze exception")
+	}
+
+	return bot.tryMerge(pr, "", cfg, false, log)
+}
+
+// precheckFreezeException tells the commenter that the command is useless if no freeze entry
+// applies to the target branch, in which case no one is the owner of the frozen branch.
+func (bot *robot) precheckFreezeException(ev *commandEvent, cmd command, cfg *botConfig, log *logrus.Entry) (string, error) {
+	if !cmdAddFreezeException.match(cmd) && !cmdRemoveFreezeException.match(cmd) {
+		return "", nil
+	}
+
+	fm, branch, err := bot.getFreezeOfPR(ev.pr, cfg, log)
+	if err != nil || fm != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(msgNoFreezeOfBranch, ev.commenter, branch), nil
+}
+
+// getFreezeOfPR returns the freeze entry which applies to the target branch of pr and the branch.
+func (bot *robot) getFreezeOfPR(pr gc.PRInfo, cfg *botConfig, log *logrus.Entry) (*freezeMatch, string, error) {
+	sp, err := bot.cli.GetSinglePR(pr.Org, pr.Repo, pr.Number)
+	if err != nil {
+		return nil, "", err
+	}
+
+	h := mergeHelper{
+		pr:    sp,
+		cfg:   cfg,
+		org:   pr.Org,
+		repo:  pr.Repo,
+		cli:   bot.cli,
+		cache: bot.cache,
+	}
+
+	fm, err := h.getFreezeInfo(log)
+
+	return fm, sp.GetBase().GetRef(), err
+}
+
+// hasFreezeException tells whether the PR has the freeze exception label and
+// the label was added by the legal actors, which means it was added by the robot
+// on behalf of a branch owner rather than by someone manually.
+func hasFreezeException(labels sets.String, ops []*sdk.Timeline, cfg *botConfig, log *logrus.Entry) bool {
+	if !labels.Has(freezeExceptionLabel) {
+		return false
+	}
+
+	v, ok := getLatestLog(ops, freezeExceptionLabel, log)
+	if !ok {
+		return false
+	}
+
+	return cfg.legalActorsOf(freezeExceptionLabel).isLegal(v.who)
+}
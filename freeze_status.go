@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	sdk "github.com/google/go-github/v36/github"
+	gc "github.com/opensourceways/robot-github-lib/client"
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	actionOpened = "opened"
+	actionEdited = "edited"
+
+	msgFrozenBranchNotice = "@%s , the target branch %s of this pr is frozen, and the pr can be merged only by the branch owners or with their /freeze-exception: %s. %s"
+	msgFreezeLiftsAt      = "The freeze lifts at %s."
+	msgFreezeNoEnd        = "The freeze has no scheduled end."
+	msgFreezeStatus       = "@%s , the branch %s is %s. %s\n%s\n\n```yaml\n%s```"
+)
+
+var cmdFreezeStatus = newCommandMatcher("/freeze-status")
+
+// notifyFrozenBranch tells the author up front that the target branch of PR is frozen
+// when the PR is opened or its target branch is changed.
+func (bot *robot) notifyFrozenBranch(e *sdk.PullRequestEvent, p gc.PRInfo, cfg *botConfig, log *logrus.Entry) error {
+	if len(cfg.FreezeFile) == 0 || e.GetPullRequest().GetState() != open {
+		return nil
+	}
+
+	action := e.GetAction()
+	if action != actionOpened && !(action == actionEdited && e.GetChanges() != nil && e.GetChanges().Base != nil) {
+		return nil
+	}
+
+	h := mergeHelper{
+		pr:    e.GetPullRequest(),
+		cfg:   cfg,
+		org:   p.Org,
+		repo:  p.Repo,
+		cli:   bot.cli,
+		cache: bot.cache,
+	}
+
+	fm, err := h.getFreezeInfo(log)
+	if err != nil || fm == nil {
+		return err
+	}
+
+	frozen, until, err := fm.item.frozenAt(time.Now())
+	if err != nil || !frozen {
+		return err
+	}
+
+	return bot.cli.CreatePRComment(p, fmt.Sprintf(
+		msgFrozenBranchNotice,
+		e.GetPullRequest().GetUser().GetLogin(), e.GetPullRequest().GetBase().GetRef(),
+		strings.Join(fm.item.Owner, ", "), freezeLiftsDesc(until),
+	))
+}
+
+func (bot *robot) handleFreezeStatus(ev *commandEvent, cmd command, cfg *botConfig, log *logrus.Entry) error {
+	if !cmdFreezeStatus.match(cmd) {
+		return nil
+	}
+
+	pr, commenter := ev.pr, ev.commenter
+
+	fm, branch, err := bot.getFreezeOfPR(pr, cfg, log)
+	if err != nil {
+		return err
+	}
+
+	if fm == nil {
+		return bot.cli.CreatePRComment(pr, fmt.Sprintf(msgNoFreezeOfBranch, commenter, branch))
+	}
+
+	frozen, until, err := fm.item.frozenAt(time.Now())
+	if err != nil {
+		return err
+	}
+
+	state, desc := "not frozen now", ""
+	if frozen {
+		state, desc = "frozen", freezeLiftsDesc(until)
+	}
+
+	entry, err := yaml.Marshal(fm.item)
+	if err != nil {
+		return err
+	}
+
+	return bot.cli.CreatePRComment(pr, fmt.Sprintf(
+		msgFreezeStatus, commenter, branch, state, desc, fm.desc(), string(entry),
+	))
+}
+
+func freezeLiftsDesc(until time.Time) string {
+	if until.IsZero() {
+		return msgFreezeNoEnd
+	}
+
+	return fmt.Sprintf(msgFreezeLiftsAt, until.Format(time.RFC3339))
+}
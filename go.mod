@@ -8,6 +8,7 @@ require (
 	github.com/opensourceways/robot-github-lib v0.0.0-20230316091316-fdc6ce9d0989
 	github.com/opensourceways/server-common-lib v0.0.0-20230208064916-61fc43dfb8db
 	github.com/sirupsen/logrus v1.9.0
+	golang.org/x/oauth2 v0.0.0-20220411215720-9780585627b5
 	k8s.io/apimachinery v0.26.1
 	sigs.k8s.io/yaml v1.3.0
 )
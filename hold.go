@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+
+	sdk "github.com/google/go-github/v36/github"
+	gc "github.com/opensourceways/robot-github-lib/client"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// holdLabel blocks merging until it is removed by /unhold.
+	holdLabel   = "do-not-merge/hold"
+	labelRemove = "unlabeled"
+
+	commentHold   = `***%s*** was added to this pull request by: ***%s***. The pull request will not be merged until it is removed by /unhold. :raised_hand: `
+	commentUnhold = `***%s*** was removed in this pull request by: ***%s***. :wave: `
+	msgOnHold     = "PR is on hold and it has the label: %s"
+)
+
+var (
+	cmdHold       = newCommandMatcher("/hold")
+	cmdUnhold     = newCommandMatcher("/unhold")
+	cmdHoldCancel = newCommandMatcher("/hold cancel")
+)
+
+func (bot *robot) handleHold(ev *commandEvent, cmd command, cfg *botConfig, log *logrus.Entry) error {
+	hold := cmdHold.match(cmd)
+	unhold := cmdUnhold.match(cmd) || cmdHoldCancel.match(cmd)
+	if !hold && !unhold {
+		return nil
+	}
+
+	pr, commenter := ev.pr, ev.commenter
+
+	if unhold {
+		if err := bot.cli.RemovePRLabel(pr, holdLabel); err != nil {
+			return err
+		}
+
+		// the PR is tried to merge by handleHoldRemoved on the event of removing the label.
+		return bot.cli.CreatePRComment(pr, fmt.Sprintf(commentUnhold, holdLabel, commenter))
+	}
+
+	if err := bot.createLabelIfNeed(pr.Org, pr.Repo, holdLabel); err != nil {
+		log.WithError(err).Errorf("create repo label: %s", holdLabel)
+	}
+
+	if err := bot.cli.AddPRLabel(pr, holdLabel); err != nil {
+		return err
+	}
+
+	return bot.cli.CreatePRComment(pr, fmt.Sprintf(commentHold, holdLabel, commenter))
+}
+
+// handleHoldRemoved tries to merge the PR when the hold label is removed by /unhold or on the page of PR.
+func (bot *robot) handleHoldRemoved(e *sdk.PullRequestEvent, p gc.PRInfo, cfg *botConfig, log *logrus.Entry) error {
+	if e.GetAction() != labelRemove ||
+		e.GetPullRequest().GetState() != open ||
+		e.GetLabel().GetName() != holdLabel {
+		return nil
+	}
+
+	return bot.tryMerge(p, "", cfg, false, log)
+}
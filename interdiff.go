@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	sdk "github.com/google/go-github/v36/github"
+	gc "github.com/opensourceways/robot-github-lib/client"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	compareStatusAhead = "ahead"
+
+	interdiffMarker       = "<!-- interdiff base:%s -->"
+	commentInterdiff      = "**Changes since the last review**\n\nThe %s updated the pr from %s to %s, [compare](%s).\n\n%s\n" + interdiffMarker
+	msgInterdiffNoChange  = "There are no code changes, the pr was only rebased."
+	msgInterdiffUnknown   = "The changed files can't be determined, please check them by the compare link."
+	msgInterdiffForcePush = "force push"
+	msgInterdiffFastPush  = "push"
+)
+
+var regInterdiffMarker = regexp.MustCompile(`<!-- interdiff base:([0-9a-fA-F]+) -->`)
+
+// postInterdiff posts the files changed since the last review after each push. The comment is
+// updated by the later pushes until someone reviews the pr again, so it always shows the delta
+// which the reviewers have not seen.
+func (bot *robot) postInterdiff(e *sdk.PullRequestEvent, p gc.PRInfo, log *logrus.Entry) error {
+	if e.GetAction() != sourceBranchChanged || e.GetPullRequest().GetState() != open {
+		return nil
+	}
+
+	before, after := e.GetBefore(), e.GetAfter()
+	if before == "" || after == "" || before == after {
+		return nil
+	}
+
+	comments, err := bot.cli.ListIssueComments(p)
+	if err != nil {
+		return err
+	}
+
+	base := before
+
+	// only the marker in the comments of robot is trusted, otherwise anyone could move the base.
+	var summary *sdk.IssueComment
+	for _, c := range comments {
+		if !strings.EqualFold(c.GetUser().GetLogin(), bot.botLogin) {
+			continue
+		}
+
+		if m := regInterdiffMarker.FindStringSubmatch(c.GetBody()); m != nil {
+			if summary == nil || c.GetCreatedAt().After(summary.GetCreatedAt()) {
+				summary = c
+				base = m[1]
+			}
+		}
+	}
+
+	if summary != nil {
+		all, err := listPRComments(bot.cli, p)
+		if err != nil {
+			return err
+		}
+
+		if reviewedSince(all, summary.GetUpdatedAt()) {
+			summary = nil
+			base = before
+		}
+	}
+
+	kind := msgInterdiffFastPush
+	if v, err := bot.cli.CompareCommits(p.Org, p.Repo, before, after); err != nil {
+		log.WithError(err).Error("compare the commits before and after push")
+	} else if v.GetStatus() != compareStatusAhead {
+		kind = msgInterdiffForcePush
+	}
+
+	desc := msgInterdiffUnknown
+	files, known, err := bot.filesChangedBetween(p, e.GetPullRequest().GetBase().GetRef(), base, after)
+	if err != nil {
+		log.WithError(err).Error("get the files changed since the last review")
+	}
+
+	if known {
+		if len(files) == 0 {
+			desc = msgInterdiffNoChange
+		} else {
+			desc = "- " + strings.Join(files, "\n- ")
+		}
+	}
+
+	body := fmt.Sprintf(
+		commentInterdiff, kind, shortSHA(base), shortSHA(after),
+		fmt.Sprintf("%s/compare/%s..%s", e.GetRepo().GetHTMLURL(), base, after),
+		desc, base,
+	)
+
+	if summary != nil {
+		return bot.cli.UpdatePRComment(p, summary.GetID(), &sdk.IssueComment{Body: sdk.String(body)})
+	}
+
+	return bot.cli.CreatePRComment(p, body)
+}
+
+// reviewedSince tells whether someone has used /lgtm or /approve after the time t.
+func reviewedSince(comments []prComment, t time.Time) bool {
+	for i := range comments {
+		c := &comments[i]
+		if c.createdAt.After(t) && (c.has(cmdAddLgtm) || c.has(cmdAddApprove)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+
+	return sha
+}
@@ -2,12 +2,14 @@ package main
 
 import (
 	"flag"
+	"net/http"
 	"net/url"
 	"os"
 
 	cache "github.com/opensourceways/repo-file-cache/sdk"
-	"github.com/opensourceways/robot-github-lib/client"
 	"github.com/opensourceways/robot-github-lib/framework"
+	"github.com/opensourceways/server-common-lib/config"
+	"github.com/opensourceways/server-common-lib/interrupts"
 	"github.com/opensourceways/server-common-lib/logrusutil"
 	liboptions "github.com/opensourceways/server-common-lib/options"
 	"github.com/opensourceways/server-common-lib/secret"
@@ -19,6 +21,7 @@ type options struct {
 	github        liboptions.GithubOptions
 	cacheEndpoint string
 	maxRetries    int
+	shadow        bool
 }
 
 func (o *options) Validate() error {
@@ -40,6 +43,7 @@ func gatherOptions(fs *flag.FlagSet, args ...string) options {
 	o.service.AddFlags(fs)
 	fs.StringVar(&o.cacheEndpoint, "cache-endpoint", "", "The endpoint of repo file cache")
 	fs.IntVar(&o.maxRetries, "max-retries", 3, "The number of failed retry attempts to call the cache api")
+	fs.BoolVar(&o.shadow, "shadow", false, "Handle events normally but only log the write operations instead of doing them")
 
 	_ = fs.Parse(args)
 
@@ -61,10 +65,34 @@ func main() {
 
 	defer secretAgent.Stop()
 
-	c := client.NewClient(secretAgent.GetTokenGenerator(o.github.TokenPath))
+	var c iClient = newGithubClient(secretAgent.GetTokenGenerator(o.github.TokenPath))
+	if o.shadow {
+		logrus.Info("run in shadow mode, no write operations will be done")
+
+		c = newShadowClient(c)
+	}
+
+	botLogin, err := c.GetBot()
+	if err != nil {
+		logrus.WithError(err).Fatal("Error getting the login of robot.")
+	}
+
 	s := cache.NewSDK(o.cacheEndpoint, o.maxRetries)
 
-	p := newRobot(c, s)
+	p := newRobot(c, s, botLogin)
+
+	agent := config.NewConfigAgent(p.NewConfig)
+	if err := agent.Start(o.service.ConfigFile); err != nil {
+		logrus.WithError(err).Fatal("Error starting config agent for the check hook.")
+	}
+
+	h := &checkHook{bot: p, agent: &agent}
+	http.Handle(checkHookPath, h)
+
+	interrupts.OnInterrupt(func() {
+		agent.Stop()
+		h.Wait()
+	})
 
 	framework.Run(p, o.service)
 }
@@ -3,13 +3,12 @@ package main
 import (
 	"encoding/base64"
 	"fmt"
-	"regexp"
-	"strconv"
 	"strings"
 	"time"
 
 	sdk "github.com/google/go-github/v36/github"
 	gc "github.com/opensourceways/robot-github-lib/client"
+	"github.com/opensourceways/server-common-lib/utils"
 	"github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"sigs.k8s.io/yaml"
@@ -20,26 +19,24 @@ const (
 	msgMissingLabels      = "PR does not have these lables: %s"
 	msgInvalidLabels      = "PR should remove these labels: %s"
 	msgNotEnoughLGTMLabel = "PR needs %d lgtm labels and now gets %d"
-	msgFrozenWithOwner    = "The target branch of PR has been frozen and it can be merge only by branch owners: %s"
+	msgFrozenWithOwner    = "The target branch of PR has been frozen and it can be merge only by branch owners or with their /freeze-exception: %s"
+	msgFreezeLifts        = "The freeze lifts at %s, and the PR will be merged again then."
+	msgUnapprovedFiles    = "PR has files which are not approved by their approvers: %s"
 	legalLabelsAddedBy    = "openeuler-ci-bot"
 )
 
-var regCheckPr = regexp.MustCompile(`(?mi)^/check-pr\s*$`)
+var cmdCheckPR = newCommandMatcher("/check-pr")
 
-func (bot *robot) handleCheckPR(e *sdk.IssueCommentEvent, cfg *botConfig, log *logrus.Entry) error {
-	if !e.GetIssue().IsPullRequest() ||
-		!(e.GetIssue().GetState() == open) ||
-		!gc.IsCommentCreated(e) ||
-		!regCheckPr.MatchString(e.GetComment().GetBody()) {
+func (bot *robot) handleCheckPR(ev *commandEvent, cmd command, cfg *botConfig, log *logrus.Entry) error {
+	if !cmdCheckPR.match(cmd) {
 		return nil
 	}
 
-	return bot.tryMerge(e, cfg, true, log)
+	return bot.tryMerge(ev.pr, ev.commenter, cfg, true, log)
 }
 
-func (bot *robot) tryMerge(e *sdk.IssueCommentEvent, cfg *botConfig, addComment bool, log *logrus.Entry) error {
-	org, repo := gc.GetOrgRepo(e.GetRepo())
-	number := e.GetIssue().GetNumber()
+func (bot *robot) tryMerge(pr gc.PRInfo, trigger string, cfg *botConfig, addComment bool, log *logrus.Entry) error {
+	org, repo, number := pr.Org, pr.Repo, pr.Number
 
 	sp, err := bot.cli.GetSinglePR(org, repo, number)
 	if err != nil {
@@ -55,16 +52,26 @@ func (bot *robot) tryMerge(e *sdk.IssueCommentEvent, cfg *botConfig, addComment
 		repo:    repo,
 		method:  methodOfMerge,
 		cli:     bot.cli,
-		trigger: e.GetComment().GetUser().GetLogin(),
+		cache:   bot.cache,
+		trigger: trigger,
 	}
 
+	branch := sp.GetBase().GetRef()
+
 	if r, ok := h.canMerge(log); !ok {
+		bot.retryMergeAfterFreeze(&h, cfg, log)
+
 		if len(r) > 0 && addComment {
+			key := mergeQueueKey(org, repo, branch)
+			if pos := bot.queue.position(key, number); pos > 0 {
+				r = append(r, fmt.Sprintf(msgQueuePosition, pos, branch))
+			}
+
 			return bot.cli.CreatePRComment(
-				gc.PRInfo{Org: org, Repo: repo, Number: number},
+				pr,
 				fmt.Sprintf(
 					"@%s , this pr is not mergeable and the reasons are below:\n%s",
-					e.GetComment().GetUser().GetLogin(), strings.Join(r, "\n"),
+					trigger, strings.Join(r, "\n"),
 				),
 			)
 		}
@@ -72,7 +79,32 @@ func (bot *robot) tryMerge(e *sdk.IssueCommentEvent, cfg *botConfig, addComment
 		return nil
 	}
 
-	return h.merge()
+	t := mergeTask{pr: pr, cfg: cfg, trigger: trigger, log: log}
+
+	return bot.enqueueMerge(t, branch, addComment)
+}
+
+// tryMergeBySHA tries to merge the open PRs whose head commit is sha.
+// It is used when the CI of a commit has finished.
+func (bot *robot) tryMergeBySHA(org, repo, sha string, cfg *botConfig, log *logrus.Entry) error {
+	prs, err := bot.cli.GetPullRequests(gc.PRInfo{Org: org, Repo: repo})
+	if err != nil {
+		return err
+	}
+
+	merr := utils.NewMultiErrors()
+	for _, v := range prs {
+		if v.GetState() != open || v.GetHead().GetSHA() != sha {
+			continue
+		}
+
+		pr := gc.PRInfo{Org: org, Repo: repo, Number: v.GetNumber()}
+		if err := bot.tryMerge(pr, "", cfg, false, log); err != nil {
+			merr.AddError(err)
+		}
+	}
+
+	return merr.Err()
 }
 
 func (bot *robot) handleLabelUpdate(e *sdk.PullRequestEvent, p gc.PRInfo, cfg *botConfig, log *logrus.Entry) error {
@@ -81,18 +113,24 @@ func (bot *robot) handleLabelUpdate(e *sdk.PullRequestEvent, p gc.PRInfo, cfg *b
 	}
 
 	h := mergeHelper{
-		cfg:  cfg,
-		org:  p.Org,
-		repo: p.Repo,
-		cli:  bot.cli,
-		pr:   e.GetPullRequest(),
+		cfg:   cfg,
+		org:   p.Org,
+		repo:  p.Repo,
+		cli:   bot.cli,
+		cache: bot.cache,
+		pr:    e.GetPullRequest(),
 	}
 
-	if _, ok := h.canMerge(log); ok {
-		return h.merge()
+	if _, ok := h.canMerge(log); !ok {
+		bot.retryMergeAfterFreeze(&h, cfg, log)
+
+		return nil
 	}
 
-	return nil
+	return bot.enqueueMerge(
+		mergeTask{pr: p, cfg: cfg, log: log},
+		e.GetPullRequest().GetBase().GetRef(), false,
+	)
 }
 
 type mergeHelper struct {
@@ -104,48 +142,29 @@ type mergeHelper struct {
 	method  string
 	trigger string
 
-	cli iClient
+	cli   iClient
+	cache *fileCache
+
+	// frozenUntil is the time when the freeze lifts if the PR is blocked by a scheduled freeze.
+	frozenUntil time.Time
 }
 
 func (m *mergeHelper) merge() error {
-	number := m.pr.GetNumber()
+	method := m.method
+	if p := m.cfg.trailerProfile; p != nil && p.UseConfiguredMergeMethod {
+		method = string(m.cfg.MergeMethod)
+	}
 
-	desc := m.genMergeDesc()
-
-	bodyStr := ""
-	if m.org == "openeuler" && m.repo == "kernel" {
-		author := m.pr.GetUser().GetLogin()
-		if author == "openeuler-sync-bot" {
-			bodySlice := strings.Split(*m.pr.Body, "\n")
-			originPR := strings.Split(strings.Replace(bodySlice[1], "### ", "", -1), "1. ")[1]
-			syncRelatedPR := bodySlice[2]
-
-			relatedPRNumber, _ := strconv.Atoi(strings.Replace(
-				strings.Split(syncRelatedPR, "/")[6], "\r", "", -1))
-			relatedOrg := strings.Split(syncRelatedPR, "/")[3]
-			relatedRepo := strings.Split(syncRelatedPR, "/")[4]
-			relatedPR, _ := m.cli.GetPullRequests(gc.PRInfo{Org: relatedOrg, Repo: relatedRepo, Number: relatedPRNumber})
-			relatedDesc := relatedPR[0].Body
-
-			bodyStr = fmt.Sprintf("\n%s \n%s \n \n%s", originPR, syncRelatedPR, relatedDesc)
-		} else {
-			bodyStr = *m.pr.Body
-		}
-		return m.cli.MergePR(
-			gc.PRInfo{Org: m.org, Repo: m.repo, Number: number},
-			fmt.Sprintf("\n%s \n \n%s \n \n%s \n%s", fmt.Sprintf("Merge Pull Request from: @%s",
-				m.pr.User.GetLogin()), bodyStr, fmt.Sprintf("Link:%s", m.pr.GetHTMLURL()), desc),
-			&sdk.PullRequestOptions{
-				MergeMethod: string(m.cfg.MergeMethod),
-			},
-		)
+	msg, err := m.genCommitMessage(method)
+	if err != nil {
+		return err
 	}
 
 	return m.cli.MergePR(
-		gc.PRInfo{Org: m.org, Repo: m.repo, Number: number},
-		fmt.Sprintf("\n%s", desc),
+		gc.PRInfo{Org: m.org, Repo: m.repo, Number: m.pr.GetNumber()},
+		msg,
 		&sdk.PullRequestOptions{
-			MergeMethod: m.method,
+			MergeMethod: method,
 		},
 	)
 }
@@ -166,20 +185,54 @@ func (m *mergeHelper) canMerge(log *logrus.Entry) ([]string, bool) {
 		return []string{}, false
 	}
 
-	if r := isLabelMatched(m.getPRLabels(), m.cfg, ops, log); len(r) > 0 {
+	labels := m.getPRLabels()
+	r := isLabelMatched(labels, m.cfg, ops, log)
+
+	if m.cfg.ApproveBasedOnOwners {
+		h, err := newApproveHelper(m.cache, m.cli, p, m.pr.GetBase().GetRef(), log)
+		if err != nil {
+			return []string{}, false
+		}
+
+		files, err := h.unapprovedFiles(m.pr.GetUser().GetLogin())
+		if err != nil {
+			return []string{}, false
+		}
+
+		if len(files) > 0 {
+			r = append(r, fmt.Sprintf(msgUnapprovedFiles, strings.Join(files, ", ")))
+		}
+	}
+
+	if len(r) > 0 {
 		return r, false
 	}
 
-	freeze, err := m.getFreezeInfo(log)
+	fm, err := m.getFreezeInfo(log)
+	if err != nil {
+		return nil, false
+	}
+
+	if fm == nil {
+		return nil, true
+	}
+
+	freeze := fm.item
+
+	frozen, until, err := freeze.frozenAt(time.Now())
 	if err != nil {
+		log.WithError(err).Errorf("check freeze of branch:%s", freeze.Branch)
+
 		return nil, false
 	}
 
-	if freeze == nil || !freeze.isFrozen() {
+	if !frozen || hasFreezeException(labels, ops, m.cfg, log) {
 		return nil, true
 	}
 
 	if m.trigger == "" {
+		m.frozenUntil = until
+
 		return nil, false
 	}
 
@@ -187,37 +240,51 @@ func (m *mergeHelper) canMerge(log *logrus.Entry) ([]string, bool) {
 		return nil, true
 	}
 
-	return []string{
+	m.frozenUntil = until
+
+	r = []string{
 		fmt.Sprintf(msgFrozenWithOwner, strings.Join(freeze.Owner, ", ")),
-	}, false
+		fm.desc(),
+	}
+	if !until.IsZero() {
+		r = append(r, fmt.Sprintf(msgFreezeLifts, until.Format(time.RFC3339)))
+	}
+
+	return r, false
 }
 
-func (m *mergeHelper) getFreezeInfo(log *logrus.Entry) (*freezeItem, error) {
+// getFreezeInfo returns the freeze entry of the target branch which is chosen from
+// all the matched entries of freeze files by the freeze precedence.
+func (m *mergeHelper) getFreezeInfo(log *logrus.Entry) (*freezeMatch, error) {
 	branch := m.pr.GetBase().GetRef()
-	for _, v := range m.cfg.FreezeFile {
-		fc, err := m.getFreezeContent(v)
+
+	var matches []freezeMatch
+	for i := range m.cfg.FreezeFile {
+		f := &m.cfg.FreezeFile[i]
+
+		fc, err := m.getFreezeContent(*f)
 		if err != nil {
-			log.Errorf("get freeze file:%s, err:%s", v.toString(), err.Error())
+			log.Errorf("get freeze file:%s, err:%s", f.toString(), err.Error())
 			return nil, err
 		}
 
-		if v := fc.getFreezeItem(m.org, branch); v != nil {
-			return v, nil
+		for _, v := range fc.getFreezeItems(m.org, m.repo, branch) {
+			matches = append(matches, freezeMatch{item: v, file: f})
 		}
 	}
 
-	return nil, nil
+	return selectFreeze(matches, m.cfg.FreezePrecedence, time.Now(), log), nil
 }
 
 func (m *mergeHelper) getFreezeContent(f freezeFile) (freezeContent, error) {
 	var fc freezeContent
 
-	c, err := m.cli.GetPathContent(f.Owner, f.Repo, f.Path, f.Branch)
+	c, err := m.cache.getFileContent(f.Owner, f.Repo, f.Branch, f.Path)
 	if err != nil {
 		return fc, err
 	}
 
-	b, err := base64.StdEncoding.DecodeString(*c.Content)
+	b, err := base64.StdEncoding.DecodeString(c)
 	if err != nil {
 		return fc, err
 	}
@@ -253,137 +320,6 @@ func (m *mergeHelper) getPRLabels() sets.String {
 	return labels
 }
 
-func (m *mergeHelper) genMergeDesc() string {
-	comments, err := m.cli.ListIssueComments(gc.PRInfo{Org: m.org, Repo: m.repo, Number: m.pr.GetNumber()})
-	if err != nil || len(comments) == 0 {
-		return ""
-	}
-
-	f := func(comment *sdk.IssueComment, reg *regexp.Regexp) bool {
-		return reg.MatchString(comment.GetBody()) &&
-			comment.GetUpdatedAt() == comment.GetCreatedAt() &&
-			comment.GetUser().GetLogin() != m.pr.GetUser().GetLogin()
-	}
-
-	f2 := func(comment *sdk.IssueComment, reg *regexp.Regexp) bool {
-		return reg.MatchString(*comment.Body) &&
-			comment.GetUser().GetLogin() != m.pr.GetUser().GetLogin()
-	}
-
-	reviewers := sets.NewString()
-	signers := sets.NewString()
-	ackers := sets.NewString()
-
-	org, repo := m.org, m.repo
-	for _, c := range comments {
-		if org == "openeuler" && repo == "kernel" {
-			if f2(c, regAddLgtm) {
-				reviewers.Insert(c.GetUser().GetLogin())
-			}
-
-			if f2(c, regAddApprove) {
-				signers.Insert(c.GetUser().GetLogin())
-			}
-
-			if f2(c, regAck) {
-				ackers.Insert(c.GetUser().GetLogin())
-			}
-		}
-
-		if f(c, regAddLgtm) {
-			reviewers.Insert(c.GetUser().GetLogin())
-		}
-
-		if f(c, regAddApprove) {
-			signers.Insert(c.GetUser().GetLogin())
-		}
-	}
-
-	if len(signers) == 0 && len(reviewers) == 0 && len(ackers) == 0 {
-		return ""
-	}
-
-	// kernel return the name and email address
-	if org == "openeuler" && repo == "kernel" {
-		content, err := m.cli.GetPathContent("openeuler", "community", "sig/Kernel/sig-info.yaml", "master")
-		if err != nil {
-			return ""
-		}
-
-		c, err := base64.StdEncoding.DecodeString(*content.Content)
-		if err != nil {
-			return ""
-		}
-
-		var s SigInfos
-
-		if err = yaml.Unmarshal(c, &s); err != nil {
-			return ""
-		}
-
-		nameEmail := make(map[string]string, len(s.Maintainers))
-		for _, ms := range s.Maintainers {
-			nameEmail[ms.GiteeID] = fmt.Sprintf("%s <%s>", ms.Name, ms.Email)
-		}
-
-		for _, i := range s.Repositories {
-			for _, j := range i.Committers {
-				nameEmail[j.GiteeID] = fmt.Sprintf("%s <%s>", j.Name, j.Email)
-			}
-		}
-
-		reviewersInfo := sets.NewString()
-		for r, _ := range reviewers {
-			if v, ok := nameEmail[r]; ok {
-				reviewersInfo.Insert(v)
-			}
-		}
-
-		signersInfo := sets.NewString()
-		for s, _ := range signers {
-			if v, ok := nameEmail[s]; ok {
-				signersInfo.Insert(v)
-			}
-		}
-
-		ackersInfo := sets.NewString()
-		for a, _ := range ackers {
-			if v, ok := nameEmail[a]; ok {
-				ackersInfo.Insert(v)
-			}
-		}
-
-		reviewedUserInfo := make([]string, 0)
-		for _, item := range reviewersInfo.UnsortedList() {
-			reviewedUserInfo = append(reviewedUserInfo, fmt.Sprintf("Reviewed-by: %s \n", item))
-		}
-
-		signedOffUserInfo := make([]string, 0)
-		for _, item := range signersInfo.UnsortedList() {
-			signedOffUserInfo = append(signedOffUserInfo, fmt.Sprintf("Signed-off-by: %s \n", item))
-		}
-
-		ackeByUserInfo := make([]string, 0)
-		for _, item := range ackersInfo.UnsortedList() {
-			ackeByUserInfo = append(ackeByUserInfo, fmt.Sprintf("Acked-by: %s \n", item))
-		}
-
-		return fmt.Sprintf(
-			"\n%s%s%s",
-			strings.Join(reviewedUserInfo, ""),
-			strings.Join(signedOffUserInfo, ""),
-			strings.Join(ackeByUserInfo, ""),
-		)
-	}
-
-	return fmt.Sprintf(
-		"From: @%s \nReviewed-by: @%s \nSigned-off-by: @%s \n",
-		m.pr.User.Login,
-		strings.Join(reviewers.UnsortedList(), ", @"),
-		strings.Join(signers.UnsortedList(), ", @"),
-	)
-}
-
 func isLabelMatched(labels sets.String, cfg *botConfig, ops []*sdk.Timeline, log *logrus.Entry) []string {
 	var reasons []string
 
@@ -399,7 +335,7 @@ func isLabelMatched(labels sets.String, cfg *botConfig, ops []*sdk.Timeline, log
 		}
 	}
 
-	s := checkLabelsLegal(labels, needs, ops, log)
+	s := checkLabelsLegal(labels, needs, ops, cfg, log)
 	if s != "" {
 		reasons = append(reasons, s)
 	}
@@ -410,6 +346,14 @@ func isLabelMatched(labels sets.String, cfg *botConfig, ops []*sdk.Timeline, log
 		))
 	}
 
+	if labels.Has(changesRequestedLabel) {
+		reasons = append(reasons, fmt.Sprintf(msgChangesRequested, changesRequestedLabel))
+	}
+
+	if labels.Has(holdLabel) {
+		reasons = append(reasons, fmt.Sprintf(msgOnHold, holdLabel))
+	}
+
 	if len(cfg.MissingLabelsForMerge) > 0 {
 		missing := sets.NewString(cfg.MissingLabelsForMerge...)
 		if v := missing.Intersection(labels); v.Len() > 0 {
@@ -466,7 +410,9 @@ func getLatestLog(ops []*sdk.Timeline, label string, log *logrus.Entry) (labelLo
 	return labelLog{}, false
 }
 
-func checkLabelsLegal(labels sets.String, needs sets.String, ops []*sdk.Timeline, log *logrus.Entry) string {
+func checkLabelsLegal(
+	labels sets.String, needs sets.String, ops []*sdk.Timeline, cfg *botConfig, log *logrus.Entry,
+) string {
 	f := func(label string) string {
 		v, b := getLatestLog(ops, label, log)
 		if !b {
@@ -474,7 +420,7 @@ func checkLabelsLegal(labels sets.String, needs sets.String, ops []*sdk.Timeline
 				"the label and add it again by correct way")
 		}
 
-		if v.who != legalLabelsAddedBy {
+		if !cfg.legalActorsOf(label).isLegal(v.who) {
 			if strings.HasPrefix(v.label, "openeuler-cla/") {
 				return fmt.Sprintf("%s You can't add %s by yourself, "+
 					"please remove it and use /check-cla to add it", v.who, v.label)
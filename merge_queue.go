@@ -0,0 +1,279 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	sdk "github.com/google/go-github/v36/github"
+	gc "github.com/opensourceways/robot-github-lib/client"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// waitMergeableTimes and waitMergeableInterval decide how long to wait for
+	// github to recompute the mergeability of a PR after the previous merge.
+	waitMergeableTimes    = 10
+	waitMergeableInterval = 3 * time.Second
+
+	msgQueuePosition    = "This pr is at position %d of the merge queue of branch %s."
+	msgDequeued         = "This pr was removed from the merge queue because it is not mergeable now, and the reasons are below:\n%s\nComment \"/check-pr\" to try again."
+	msgMergeableUnknown = "Github has not computed whether the PR is mergeable."
+)
+
+type mergeTask struct {
+	pr      gc.PRInfo
+	cfg     *botConfig
+	trigger string
+	log     *logrus.Entry
+
+	// requeued means the task has been put back to the end of queue once
+	// because the mergeability of PR was unknown.
+	requeued bool
+}
+
+type branchQueue struct {
+	tasks   []mergeTask
+	running bool
+
+	// baseSHA is the head of the base branch after the last merge.
+	baseSHA string
+}
+
+// mergeQueue serializes the merges of PRs which have the same base branch.
+// The handler which adds the first task to an idle queue is responsible for
+// processing the queue until it is empty.
+type mergeQueue struct {
+	lock   sync.Mutex
+	queues map[string]*branchQueue
+}
+
+func newMergeQueue() *mergeQueue {
+	return &mergeQueue{queues: make(map[string]*branchQueue)}
+}
+
+func mergeQueueKey(org, repo, branch string) string {
+	return fmt.Sprintf("%s/%s/%s", org, repo, branch)
+}
+
+// push adds the task to the queue of key. It returns the position of task in the queue
+// and whether the caller should start processing the queue.
+func (q *mergeQueue) push(key string, t mergeTask) (int, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	bq, ok := q.queues[key]
+	if !ok {
+		bq = &branchQueue{}
+		q.queues[key] = bq
+	}
+
+	for i := range bq.tasks {
+		if bq.tasks[i].pr.Number == t.pr.Number {
+			bq.tasks[i] = t
+
+			return i + 1, false
+		}
+	}
+
+	bq.tasks = append(bq.tasks, t)
+
+	if bq.running {
+		return len(bq.tasks), false
+	}
+
+	bq.running = true
+
+	return len(bq.tasks), true
+}
+
+// next removes the first task which has been handled, records the head of base
+// branch after it, and returns the next task.
+func (q *mergeQueue) next(key, baseSHA string) (mergeTask, string, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	bq, ok := q.queues[key]
+	if !ok {
+		return mergeTask{}, "", false
+	}
+
+	if len(bq.tasks) > 0 {
+		bq.tasks = bq.tasks[1:]
+	}
+
+	if baseSHA != "" {
+		bq.baseSHA = baseSHA
+	}
+
+	if len(bq.tasks) == 0 {
+		bq.running = false
+		bq.baseSHA = ""
+
+		return mergeTask{}, "", false
+	}
+
+	return bq.tasks[0], bq.baseSHA, true
+}
+
+// requeue puts the task back to the end of the queue of key.
+// The first task is still removed by next.
+func (q *mergeQueue) requeue(key string, t mergeTask) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if bq, ok := q.queues[key]; ok {
+		bq.tasks = append(bq.tasks, t)
+	}
+}
+
+// position returns the position of PR in the queue of key, 0 means it is not in the queue.
+func (q *mergeQueue) position(key string, number int) int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if bq, ok := q.queues[key]; ok {
+		for i := range bq.tasks {
+			if bq.tasks[i].pr.Number == number {
+				return i + 1
+			}
+		}
+	}
+
+	return 0
+}
+
+// enqueueMerge adds the PR to the merge queue of its base branch,
+// and processes the queue if it is idle.
+func (bot *robot) enqueueMerge(t mergeTask, branch string, addComment bool) error {
+	key := mergeQueueKey(t.pr.Org, t.pr.Repo, branch)
+
+	pos, start := bot.queue.push(key, t)
+
+	var err error
+	if addComment {
+		err = bot.cli.CreatePRComment(t.pr, fmt.Sprintf(
+			"@%s , %s", t.trigger, fmt.Sprintf(msgQueuePosition, pos, branch),
+		))
+	}
+
+	if start {
+		bot.runMergeQueue(key, t)
+	}
+
+	return err
+}
+
+func (bot *robot) runMergeQueue(key string, t mergeTask) {
+	baseSHA := ""
+
+	for ok := true; ok; {
+		sha, err := bot.safeMergeInQueue(key, t, baseSHA)
+		if err != nil {
+			t.log.WithError(err).Errorf("merge %s in queue", t.pr.String())
+		}
+
+		t, baseSHA, ok = bot.queue.next(key, sha)
+	}
+}
+
+// safeMergeInQueue recovers from the panic of merging, otherwise the queue would stay running
+// and no more PR of the branch could be merged.
+func (bot *robot) safeMergeInQueue(key string, t mergeTask, baseSHA string) (sha string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return bot.mergeInQueue(key, t, baseSHA)
+}
+
+// mergeInQueue re-validates the PR and merges it. It returns the head of base branch after merging.
+func (bot *robot) mergeInQueue(key string, t mergeTask, baseSHA string) (string, error) {
+	sp, err := bot.waitMergeable(t.pr, baseSHA)
+	if err != nil {
+		return "", err
+	}
+
+	if sp.GetState() != open {
+		return "", nil
+	}
+
+	if sp.Mergeable == nil {
+		if !t.requeued {
+			t.requeued = true
+			bot.queue.requeue(key, t)
+
+			return "", nil
+		}
+
+		return "", bot.notifyDequeued(t, []string{msgMergeableUnknown})
+	}
+
+	h := mergeHelper{
+		pr:      sp,
+		cfg:     t.cfg,
+		org:     t.pr.Org,
+		repo:    t.pr.Repo,
+		method:  bot.genMergeMethod(sp, t.pr.Org, t.pr.Repo, t.log),
+		cli:     bot.cli,
+		cache:   bot.cache,
+		trigger: t.trigger,
+	}
+
+	if r, ok := h.canMerge(t.log); !ok {
+		bot.retryMergeAfterFreeze(&h, t.cfg, t.log)
+
+		t.log.Infof("%s is not mergeable any more, reasons: %v", t.pr.String(), r)
+
+		return "", bot.notifyDequeued(t, r)
+	}
+
+	if err := h.merge(); err != nil {
+		return "", err
+	}
+
+	merged, err := bot.cli.GetSinglePR(t.pr.Org, t.pr.Repo, t.pr.Number)
+	if err != nil {
+		return "", err
+	}
+
+	return merged.GetMergeCommitSHA(), nil
+}
+
+// notifyDequeued tells why the PR is removed from the queue without being merged.
+func (bot *robot) notifyDequeued(t mergeTask, reasons []string) error {
+	if len(reasons) == 0 {
+		return nil
+	}
+
+	msg := fmt.Sprintf(msgDequeued, strings.Join(reasons, "\n"))
+	if t.trigger != "" {
+		msg = fmt.Sprintf("@%s , %s", t.trigger, msg)
+	}
+
+	return bot.cli.CreatePRComment(t.pr, msg)
+}
+
+// waitMergeable gets the PR until github has recomputed its mergeability
+// on top of baseSHA, or the waiting is timeout.
+func (bot *robot) waitMergeable(pr gc.PRInfo, baseSHA string) (*sdk.PullRequest, error) {
+	for i := 1; ; i++ {
+		sp, err := bot.cli.GetSinglePR(pr.Org, pr.Repo, pr.Number)
+		if err != nil {
+			return nil, err
+		}
+
+		if sp.Mergeable != nil && (baseSHA == "" || sp.GetBase().GetSHA() == baseSHA) {
+			return sp, nil
+		}
+
+		if i >= waitMergeableTimes {
+			return sp, nil
+		}
+
+		time.Sleep(waitMergeableInterval)
+	}
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"encoding/base64"
+	"path"
+	"sort"
+	"strings"
+
+	gc "github.com/opensourceways/robot-github-lib/client"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/yaml"
+)
+
+const rootDir = "."
+
+type ownersConfig struct {
+	Approvers   []string `json:"approvers,omitempty"`
+	Reviewers   []string `json:"reviewers,omitempty"`
+	Maintainers []string `json:"maintainers,omitempty"`
+	Committers  []string `json:"committers,omitempty"`
+	Options     struct {
+		NoParentOwners bool `json:"no_parent_owners,omitempty"`
+	} `json:"options,omitempty"`
+}
+
+// approvers returns the approvers declared by the OWNERS file.
+// The maintainers of sig are approvers too.
+func (o *ownersConfig) approvers() sets.String {
+	r := sets.NewString()
+
+	for _, v := range o.Approvers {
+		r.Insert(strings.ToLower(v))
+	}
+
+	for _, v := range o.Maintainers {
+		r.Insert(strings.ToLower(v))
+	}
+
+	return r
+}
+
+// reviewers returns all the users declared by the OWNERS file.
+func (o *ownersConfig) reviewers() sets.String {
+	r := o.approvers()
+
+	for _, v := range o.Reviewers {
+		r.Insert(strings.ToLower(v))
+	}
+
+	for _, v := range o.Committers {
+		r.Insert(strings.ToLower(v))
+	}
+
+	return r
+}
+
+// repoOwners holds all the OWNERS files of a branch, it is keyed by the directory of OWNERS file.
+type repoOwners map[string]*ownersConfig
+
+// approversOf returns the approvers of file. It walks up the directory tree of file,
+// and gathers the approvers of every OWNERS file until the root or an OWNERS file
+// which sets no_parent_owners.
+func (r repoOwners) approversOf(file string) sets.String {
+	return r.usersOf(file, (*ownersConfig).approvers)
+}
+
+// reviewersOf returns all the users declared by the OWNERS files of file in the same way as approversOf.
+func (r repoOwners) reviewersOf(file string) sets.String {
+	return r.usersOf(file, (*ownersConfig).reviewers)
+}
+
+func (r repoOwners) usersOf(file string, users func(*ownersConfig) sets.String) sets.String {
+	v := sets.NewString()
+
+	for _, dir := range r.dirsOf(file) {
+		v = v.Union(users(r[dir]))
+	}
+
+	return v
+}
+
+// dirsOf returns the directories of the OWNERS files which apply to the file, from the nearest one.
+func (r repoOwners) dirsOf(file string) []string {
+	var dirs []string
+
+	for dir := path.Dir(file); ; dir = path.Dir(dir) {
+		if o, ok := r[dir]; ok {
+			dirs = append(dirs, dir)
+
+			if o.Options.NoParentOwners {
+				break
+			}
+		}
+
+		if dir == rootDir || dir == "/" {
+			break
+		}
+	}
+
+	return dirs
+}
+
+// approveHelper checks the approvals of a pr based on the OWNERS files of its target branch.
+type approveHelper struct {
+	pr     gc.PRInfo
+	cli    iClient
+	files  []string
+	owners repoOwners
+	log    *logrus.Entry
+}
+
+func newApproveHelper(
+	fc *fileCache, cli iClient, pr gc.PRInfo, branch string, log *logrus.Entry,
+) (*approveHelper, error) {
+	owners, err := fc.getRepoOwners(pr.Org, pr.Repo, branch, log)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := getChangedFiles(cli, pr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &approveHelper{
+		pr:     pr,
+		cli:    cli,
+		files:  files,
+		owners: owners,
+		log:    log,
+	}, nil
+}
+
+// canApprove tells whether the user is an approver of at least one changed file.
+// The files which have no approvers can be approved by the collaborators who have write permission.
+func (h *approveHelper) canApprove(user string) (bool, error) {
+	user = strings.ToLower(user)
+
+	hasOrphan := false
+	for _, f := range h.files {
+		v := h.owners.approversOf(f)
+		if v.Has(user) {
+			return true, nil
+		}
+
+		if v.Len() == 0 {
+			hasOrphan = true
+		}
+	}
+
+	if !hasOrphan {
+		return false, nil
+	}
+
+	return hasWritePermission(h.cli, h.pr.Org, h.pr.Repo, user)
+}
+
+// unapprovedFiles returns the changed files which are not approved by any of their approvers.
+// The approvers are the ones who used /approve on the pr except the author.
+func (h *approveHelper) unapprovedFiles(author string) ([]string, error) {
+	comments, err := listPRComments(h.cli, h.pr)
+	if err != nil {
+		return nil, err
+	}
+
+	approvers := getApproversFromComments(comments, author)
+
+	var collaborators sets.String
+
+	var v []string
+	for _, f := range h.files {
+		owners := h.owners.approversOf(f)
+		if owners.Len() > 0 {
+			if !owners.HasAny(approvers.UnsortedList()...) {
+				v = append(v, f)
+			}
+
+			continue
+		}
+
+		if collaborators == nil {
+			collaborators = sets.NewString()
+
+			for a := range approvers {
+				b, err := hasWritePermission(h.cli, h.pr.Org, h.pr.Repo, a)
+				if err != nil {
+					return nil, err
+				}
+
+				if b {
+					collaborators.Insert(a)
+				}
+			}
+		}
+
+		if collaborators.Len() == 0 {
+			v = append(v, f)
+		}
+	}
+
+	sort.Strings(v)
+
+	return v, nil
+}
+
+func loadRepoOwners(cli iClient, org, repo, branch string, log *logrus.Entry) (repoOwners, error) {
+	trees, err := cli.GetDirectoryTree(org, repo, branch, true)
+	if err != nil {
+		return nil, err
+	}
+
+	r := make(repoOwners)
+
+	for _, t := range trees {
+		p := t.GetPath()
+		if t.GetType() != "blob" || path.Base(p) != ownerFile {
+			continue
+		}
+
+		c, err := cli.GetPathContent(org, repo, p, branch)
+		if err != nil || c == nil || c.Content == nil {
+			log.Errorf("get owners file:%s, err:%v", p, err)
+
+			continue
+		}
+
+		if o := decodeOwnersConfig(*c.Content, log); o != nil {
+			r[path.Dir(p)] = o
+		}
+	}
+
+	return r, nil
+}
+
+func decodeOwnersConfig(content string, log *logrus.Entry) *ownersConfig {
+	c, err := base64.StdEncoding.DecodeString(content)
+	if err != nil {
+		log.WithError(err).Error("decode file")
+
+		return nil
+	}
+
+	var o ownersConfig
+	if err = yaml.Unmarshal(c, &o); err != nil {
+		log.WithError(err).Error("code yaml file")
+
+		return nil
+	}
+
+	return &o
+}
+
+func getChangedFiles(cli iClient, pr gc.PRInfo) ([]string, error) {
+	changes, err := cli.GetPullRequestChanges(pr)
+	if err != nil {
+		return nil, err
+	}
+
+	files := sets.NewString()
+	for _, v := range changes {
+		files.Insert(v.GetFilename())
+
+		if p := v.GetPreviousFilename(); p != "" {
+			files.Insert(p)
+		}
+	}
+
+	return files.List(), nil
+}
+
+// getApproversFromComments returns the users who used /approve and did not cancel it.
+// The author of pr is excluded.
+func getApproversFromComments(comments []prComment, author string) sets.String {
+	return getCommandUsers(comments, author, cmdAddApprove, cmdRemoveApprove)
+}
+
+// getCommandUsers returns the users whose latest comment of the command is add rather than remove.
+// The comments are in the order of creation, and the author of pr is excluded.
+func getCommandUsers(comments []prComment, author string, add, remove commandMatcher) sets.String {
+	users := sets.NewString()
+
+	for i := range comments {
+		c := &comments[i]
+		if c.login == author {
+			continue
+		}
+
+		if c.has(remove) {
+			users.Delete(strings.ToLower(c.login))
+		} else if c.has(add) {
+			users.Insert(strings.ToLower(c.login))
+		}
+	}
+
+	return users
+}
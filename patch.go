@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+
+	sdk "github.com/google/go-github/v36/github"
+	gc "github.com/opensourceways/robot-github-lib/client"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// maxComparedFiles is the maximum number of files returned by the compare api of github.
+// The patches are not regarded as identical when the limit is reached.
+const maxComparedFiles = 300
+
+var regHunkHeader = regexp.MustCompile(`(?m)^@@ -\d+(,\d+)? \+\d+(,\d+)? @@`)
+
+// filesChangedByPush returns the files whose changes in the pr are different before and after
+// the push, which means the line numbers of changes are ignored, and the pr has no code change
+// if it is empty. It returns false if the files can't be determined.
+func (bot *robot) filesChangedByPush(e *sdk.PullRequestEvent, p gc.PRInfo) ([]string, bool, error) {
+	return bot.filesChangedBetween(p, e.GetPullRequest().GetBase().GetRef(), e.GetBefore(), e.GetAfter())
+}
+
+// filesChangedBetween returns the files whose changes in the pr are different between
+// the head commits from and to of pr. base is the target branch of pr.
+func (bot *robot) filesChangedBetween(p gc.PRInfo, base, from, to string) ([]string, bool, error) {
+	if from == "" || to == "" || from == to {
+		return nil, false, nil
+	}
+
+	v1, err := bot.cli.CompareCommits(p.Org, p.Repo, base, from)
+	if err != nil {
+		return nil, false, err
+	}
+
+	v2, err := bot.cli.CompareCommits(p.Org, p.Repo, base, to)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(v1.Files) >= maxComparedFiles || len(v2.Files) >= maxComparedFiles {
+		return nil, false, nil
+	}
+
+	p1, p2 := normalizePatch(v1.Files), normalizePatch(v2.Files)
+
+	files := sets.NewString()
+	for k, v := range p1 {
+		if p2[k] != v {
+			files.Insert(k)
+		}
+	}
+
+	for k := range p2 {
+		if _, ok := p1[k]; !ok {
+			files.Insert(k)
+		}
+	}
+
+	return files.List(), true, nil
+}
+
+// normalizePatch generates the text of each changed file which does not depend on the line
+// numbers of the changes. The file which has no patch, such as a binary file, is identified
+// by its content.
+func normalizePatch(files []*sdk.CommitFile) map[string]string {
+	r := make(map[string]string, len(files))
+
+	for _, f := range files {
+		content := f.GetPatch()
+		if content == "" {
+			content = f.GetSHA()
+		} else {
+			content = regHunkHeader.ReplaceAllString(content, "@@")
+		}
+
+		r[f.GetFilename()] = fmt.Sprintf("%s\n%s\n%s", f.GetPreviousFilename(), f.GetStatus(), content)
+	}
+
+	return r
+}
@@ -5,7 +5,6 @@ import (
 	"path/filepath"
 	"strings"
 
-	sdk "github.com/google/go-github/v36/github"
 	gc "github.com/opensourceways/robot-github-lib/client"
 	"github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/util/sets"
@@ -15,108 +14,246 @@ import (
 const ownerFile = "OWNERS"
 const sigInfoFile = "sig-info.yaml"
 
-func (bot *robot) hasPermission(
-	org, repo, commenter string,
-	needCheckSig bool,
-	e *sdk.IssueCommentEvent,
-	cfg *botConfig,
-	log *logrus.Entry,
-) (bool, error) {
-	commenter = strings.ToLower(commenter)
-	p, err := bot.cli.GetUserPermissionOfRepo(org, repo, commenter)
-	if err != nil {
-		return false, err
+// the kinds of users who may use a command.
+const (
+	userKindAnyone         = "anyone"
+	userKindAuthor         = "author"
+	userKindCollaborators  = "collaborators"
+	userKindAdmins         = "admins"
+	userKindSigOwners      = "sig_owners"
+	userKindSigMaintainers = "sig_maintainers"
+	userKindSigCommitters  = "sig_committers"
+	userKindApprovers      = "approvers"
+	userKindFreezeOwners   = "freeze_owners"
+)
+
+var userKindDescs = map[string]string{
+	userKindAnyone:         "anyone",
+	userKindAuthor:         "the author of pull request",
+	userKindCollaborators:  "the collaborators with write permission of repository",
+	userKindAdmins:         "the admins of repository",
+	userKindSigOwners:      "the owners of the sigs of changed files",
+	userKindSigMaintainers: "the maintainers of the sig which repository belongs to",
+	userKindSigCommitters:  "the committers of the sig which repository belongs to",
+	userKindApprovers:      "the approvers of changed files in OWNERS",
+	userKindFreezeOwners:   "the owners of the frozen target branch",
+}
+
+// allowedUsers are the users who may use a command.
+type allowedUsers struct {
+	kinds []string
+	users []string
+	teams []string
+}
+
+func (a *allowedUsers) String() string {
+	var r []string
+
+	for _, v := range a.kinds {
+		r = append(r, userKindDescs[v])
 	}
 
-	if *p.Permission == "admin" || *p.Permission == "write" {
-		return true, nil
+	for _, v := range a.users {
+		r = append(r, "@"+v)
 	}
 
-	if needCheckSig {
-		return bot.isOwnerOfSig(org, repo, commenter, e, cfg, log)
+	for _, v := range a.teams {
+		r = append(r, "the members of team "+v)
 	}
 
-	return false, nil
+	return strings.Join(r, ", ")
 }
 
-func (bot *robot) isOwnerOfSig(
-	org, repo, commenter string,
-	e *sdk.IssueCommentEvent,
-	cfg *botConfig,
-	log *logrus.Entry,
-) (bool, error) {
-	changes, err := bot.cli.GetPullRequestChanges(gc.PRInfo{Org: org, Repo: repo, Number: e.GetIssue().GetNumber()})
-	if err != nil || len(changes) == 0 {
-		return false, err
+// permissionChecker tells whether the commenter is one of the allowed users.
+// The result of each kind is cached, since a comment may contain several commands.
+type permissionChecker struct {
+	bot *robot
+	ev  *commandEvent
+	cfg *botConfig
+	log *logrus.Entry
+
+	results map[string]bool
+}
+
+func (bot *robot) newPermissionChecker(ev *commandEvent, cfg *botConfig, log *logrus.Entry) *permissionChecker {
+	return &permissionChecker{
+		bot:     bot,
+		ev:      ev,
+		cfg:     cfg,
+		log:     log,
+		results: make(map[string]bool),
 	}
+}
 
-	pathes := sets.NewString()
-	for _, file := range changes {
-		if !cfg.regSigDir.MatchString(*file.Filename) || strings.Count(*file.Filename, "/") > 2 {
-			return false, nil
+func (c *permissionChecker) isAllowed(a *allowedUsers) (bool, error) {
+	for _, v := range a.users {
+		if strings.EqualFold(v, c.ev.commenter) {
+			return true, nil
 		}
+	}
 
-		pathes.Insert(filepath.Dir(*file.Filename))
+	if b, err := c.isUserOf(a.kinds); err != nil || b {
+		return b, err
+	}
+
+	for _, v := range a.teams {
+		b, err := c.cached("team:"+v, func() (bool, error) {
+			org, team := c.ev.pr.Org, v
+			if i := strings.Index(v, "/"); i >= 0 {
+				org, team = v[:i], v[i+1:]
+			}
+
+			return c.bot.cli.IsTeamMember(org, team, c.ev.commenter)
+		})
+		if err != nil || b {
+			return b, err
+		}
 	}
 
-	// get directory tree
-	oPath, sPath, err := bot.listDirectoryTree(org, repo, "master", cfg.SigsDir)
+	return false, nil
+}
+
+func (c *permissionChecker) isUserOf(kinds []string) (bool, error) {
+	for _, kind := range kinds {
+		b, err := c.cached(kind, func() (bool, error) {
+			return c.check(kind)
+		})
+		if err != nil || b {
+			return b, err
+		}
+	}
+
+	return false, nil
+}
+
+func (c *permissionChecker) cached(key string, check func() (bool, error)) (bool, error) {
+	if v, ok := c.results[key]; ok {
+		return v, nil
+	}
+
+	b, err := check()
 	if err != nil {
-		return false, nil
+		return false, err
 	}
 
-	for _, o := range oPath {
-		p := filepath.Dir(o)
-		if !pathes.Has(p) {
-			continue
+	c.results[key] = b
+
+	return b, nil
+}
+
+func (c *permissionChecker) check(kind string) (bool, error) {
+	bot, pr, commenter := c.bot, c.ev.pr, strings.ToLower(c.ev.commenter)
+
+	switch kind {
+	case userKindAnyone:
+		return true, nil
+
+	case userKindAuthor:
+		return c.ev.commenter == c.ev.author, nil
+
+	case userKindCollaborators:
+		return hasWritePermission(bot.cli, pr.Org, pr.Repo, commenter)
+
+	case userKindAdmins:
+		p, err := bot.cli.GetUserPermissionOfRepo(pr.Org, pr.Repo, commenter)
+		if err != nil {
+			return false, err
 		}
 
-		oFile, err := bot.cli.GetPathContent(org, repo, o, "master")
-		if err != nil || oFile == nil {
+		return p.GetPermission() == "admin", nil
+
+	case userKindSigOwners:
+		return bot.isOwnerOfSig(pr.Org, pr.Repo, commenter, pr.Number, c.cfg, c.log)
+
+	case userKindSigMaintainers, userKindSigCommitters:
+		community := c.cfg.CommandPermissions.Community
+		if community == nil {
 			return false, nil
 		}
 
-		if o := decodeOwnerFile(*oFile.Content, log); !o.Has(commenter) {
+		m, ok := bot.cache.getSigMembersOfRepos(community, c.log)[pr.Org+"/"+pr.Repo]
+		if !ok {
 			return false, nil
 		}
 
-		pathes.Delete(p)
-
-		if len(pathes) == 0 {
-			return true, nil
+		if kind == userKindSigMaintainers {
+			return m.maintainers.Has(commenter), nil
 		}
-	}
 
-	for _, s := range sPath {
-		p := filepath.Dir(s)
-		if !pathes.Has(p) {
-			continue
+		return m.committers.Has(commenter), nil
+
+	case userKindApprovers:
+		h, err := bot.newApproveHelper(pr, c.log)
+		if err != nil {
+			return false, err
 		}
 
-		sFile, err := bot.cli.GetPathContent(org, repo, s, "master")
-		if err != nil || sFile == nil {
-			return false, nil
+		return h.canApprove(commenter)
+
+	case userKindFreezeOwners:
+		fm, _, err := bot.getFreezeOfPR(pr, c.cfg, c.log)
+		if err != nil || fm == nil {
+			return false, err
 		}
 
-		if o := decodeSigInfoFile(*sFile.Content, log); !o.Has(commenter) {
+		return fm.item.isOwner(c.ev.commenter), nil
+	}
+
+	return false, nil
+}
+
+func hasWritePermission(cli iClient, org, repo, user string) (bool, error) {
+	p, err := cli.GetUserPermissionOfRepo(org, repo, user)
+	if err != nil {
+		return false, err
+	}
+
+	v := p.GetPermission()
+
+	return v == "admin" || v == "write", nil
+}
+
+func (bot *robot) isOwnerOfSig(
+	org, repo, commenter string,
+	number int,
+	cfg *botConfig,
+	log *logrus.Entry,
+) (bool, error) {
+	changes, err := bot.cli.GetPullRequestChanges(gc.PRInfo{Org: org, Repo: repo, Number: number})
+	if err != nil || len(changes) == 0 {
+		return false, err
+	}
+
+	pathes := sets.NewString()
+	for _, file := range changes {
+		if !isFileOfSigDir(*file.Filename, cfg) {
 			return false, nil
 		}
 
-		pathes.Delete(p)
+		pathes.Insert(filepath.Dir(*file.Filename))
+	}
 
-		if len(pathes) == 0 {
-			return true, nil
+	owners, err := bot.cache.getSigOwners(org, repo, "master", cfg, log)
+	if err != nil {
+		log.WithError(err).Error("get owners of sigs")
+
+		return false, nil
+	}
+
+	for p := range pathes {
+		if o, ok := owners[p]; !ok || !o.Has(commenter) {
+			return false, nil
 		}
 	}
 
-	return false, nil
+	return true, nil
 }
 
-func (bot *robot) listDirectoryTree(org, repo, branch, dirPath string) ([]string, []string, error) {
+func listDirectoryTree(cli iClient, org, repo, branch, dirPath string) ([]string, []string, error) {
 	recursive := true
 	ownerFilePath := make([]string, 0)
 	sigInfoFilePath := make([]string, 0)
-	trees, err := bot.cli.GetDirectoryTree(org, repo, branch, recursive)
+	trees, err := cli.GetDirectoryTree(org, repo, branch, recursive)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -140,26 +277,32 @@ func (bot *robot) listDirectoryTree(org, repo, branch, dirPath string) ([]string
 func decodeSigInfoFile(content string, log *logrus.Entry) sets.String {
 	owners := sets.NewString()
 
-	c, err := base64.StdEncoding.DecodeString(content)
+	m, err := decodeSigInfos(content)
 	if err != nil {
-		log.WithError(err).Error("decode file")
+		log.WithError(err).Error("decode sig info file")
 
 		return owners
 	}
 
-	var m SigInfos
+	for _, v := range m.Maintainers {
+		owners.Insert(strings.ToLower(v.GiteeID))
+	}
 
-	if err = yaml.Unmarshal(c, &m); err != nil {
-		log.WithError(err).Error("code yaml file")
+	return owners
+}
 
-		return owners
+func decodeSigInfos(content string) (*SigInfos, error) {
+	c, err := base64.StdEncoding.DecodeString(content)
+	if err != nil {
+		return nil, err
 	}
 
-	for _, v := range m.Maintainers {
-		owners.Insert(strings.ToLower(v.GiteeID))
+	var m SigInfos
+	if err = yaml.Unmarshal(c, &m); err != nil {
+		return nil, err
 	}
 
-	return owners
+	return &m, nil
 }
 
 func decodeOwnerFile(content string, log *logrus.Entry) sets.String {
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	msgPermissionReport = "@%s , these are the permissions of @%s on this pull request.\n\n" +
+		"**Repository**\n\nThe permission of repository is `%s`.\n\n%s%s" +
+		"**Commands**\n\n| command | allowed |\n| --- | --- |\n%s"
+	msgSigOwnersReport      = "**Sigs of changed files**\n\n%s\n\n"
+	msgSigOwnersOutOfSigDir = "Some changed files are not in the sig directories, so no one is regarded as the owner of sigs."
+	msgOwnersFilesReport    = "**OWNERS files of changed files**\n\n%s\n\n"
+	msgNoOwnersFiles        = "No OWNERS file applies to the changed files."
+	msgRejectedForAuthor    = "only `cancel`, the author can not %s their own pr"
+)
+
+var cmdPermissions = newCommandMatcher("/permissions")
+
+// handlePermissions explains what the user can do on the pr, it is the commenter if no user is given.
+func (bot *robot) handlePermissions(ev *commandEvent, cmd command, cfg *botConfig, log *logrus.Entry) error {
+	if cmd.name != cmdPermissions.name || len(cmd.args) > 1 {
+		return nil
+	}
+
+	user := ev.commenter
+	if v := parseLogins(cmd.args); len(v) > 0 {
+		user = v[0]
+	}
+
+	pr := ev.pr
+	login := strings.ToLower(user)
+
+	u, err := bot.cli.GetUser(login)
+	if err != nil {
+		return err
+	}
+
+	target := *ev
+	target.commenter = user
+	target.isBot = u.GetType() == userTypeBot
+
+	p, err := bot.cli.GetUserPermissionOfRepo(pr.Org, pr.Repo, login)
+	if err != nil {
+		return err
+	}
+
+	files, err := getChangedFiles(bot.cli, pr)
+	if err != nil {
+		return err
+	}
+
+	sigs := ""
+	if cfg.CheckPermissionBasedOnSigOwners {
+		v, err := bot.reportSigOwners(pr.Org, pr.Repo, login, files, cfg, log)
+		if err != nil {
+			return err
+		}
+
+		sigs = fmt.Sprintf(msgSigOwnersReport, v)
+	}
+
+	owners, err := bot.reportOwnersFiles(&target, login, files, log)
+	if err != nil {
+		return err
+	}
+
+	checker := bot.newPermissionChecker(&target, cfg, log)
+
+	var rows []string
+	for i := range bot.commands {
+		spec := &bot.commands[i]
+		if !spec.isEnabled(cfg) {
+			continue
+		}
+
+		allowed := "no"
+		if cfg.CommandPermissions.isDenied(user, target.isBot) {
+			allowed = "no, the user is denied"
+		} else if b, err := checker.isAllowed(spec.allowedUsers(cfg)); err != nil {
+			allowed = "unknown"
+			log.WithError(err).Errorf("check the permission of /%s", spec.name)
+		} else if b {
+			allowed = "yes"
+
+			if strings.EqualFold(user, ev.author) && isRejectedForAuthor(spec.name, cfg) {
+				allowed = fmt.Sprintf(msgRejectedForAuthor, spec.name)
+			}
+		}
+
+		rows = append(rows, fmt.Sprintf("| %s | %s |", spec.usage(), allowed))
+	}
+
+	return bot.cli.CreatePRComment(pr, fmt.Sprintf(
+		msgPermissionReport, ev.commenter, user, p.GetPermission(),
+		sigs, fmt.Sprintf(msgOwnersFilesReport, owners), strings.Join(rows, "\n"),
+	))
+}
+
+// isRejectedForAuthor tells whether the author of pr can not add the label by the command.
+// addLGTM rejects the author, and so does unapprovedFiles when approving based on owners.
+func isRejectedForAuthor(name string, cfg *botConfig) bool {
+	return name == cmdAddLgtm.name || (name == cmdAddApprove.name && cfg.ApproveBasedOnOwners)
+}
+
+// reportSigOwners shows whether the user is the owner of each sig directory touched by the pr,
+// in the same way as isOwnerOfSig.
+func (bot *robot) reportSigOwners(
+	org, repo, login string, files []string, cfg *botConfig, log *logrus.Entry,
+) (string, error) {
+	dirs := sets.NewString()
+	outOfSigDir := false
+
+	for _, f := range files {
+		if isFileOfSigDir(f, cfg) {
+			dirs.Insert(path.Dir(f))
+		} else {
+			outOfSigDir = true
+		}
+	}
+
+	owners, err := bot.cache.getSigOwners(org, repo, "master", cfg, log)
+	if err != nil {
+		return "", err
+	}
+
+	var r []string
+	for _, d := range dirs.List() {
+		if o, ok := owners[d]; ok && o.Has(login) {
+			r = append(r, fmt.Sprintf("- `%s`: owner", d))
+		} else {
+			r = append(r, fmt.Sprintf("- `%s`: not an owner", d))
+		}
+	}
+
+	if outOfSigDir || len(r) == 0 {
+		r = append(r, msgSigOwnersOutOfSigDir)
+	}
+
+	return strings.Join(r, "\n"), nil
+}
+
+// reportOwnersFiles lists the OWNERS files which apply to the changed files and
+// whether each of them declares the user as an approver or a reviewer.
+func (bot *robot) reportOwnersFiles(ev *commandEvent, login string, files []string, log *logrus.Entry) (string, error) {
+	sp, err := bot.cli.GetSinglePR(ev.pr.Org, ev.pr.Repo, ev.pr.Number)
+	if err != nil {
+		return "", err
+	}
+
+	owners, err := bot.cache.getRepoOwners(ev.pr.Org, ev.pr.Repo, sp.GetBase().GetRef(), log)
+	if err != nil {
+		return "", err
+	}
+
+	dirs := sets.NewString()
+	for _, f := range files {
+		dirs.Insert(owners.dirsOf(f)...)
+	}
+
+	if dirs.Len() == 0 {
+		return msgNoOwnersFiles, nil
+	}
+
+	r := make([]string, 0, dirs.Len())
+	for _, d := range dirs.List() {
+		o := owners[d]
+
+		role := "does not declare the user"
+		if o.approvers().Has(login) {
+			role = "grants approve"
+		} else if o.reviewers().Has(login) {
+			role = "grants review"
+		}
+
+		r = append(r, fmt.Sprintf("- `%s`: %s", path.Join(d, ownerFile), role))
+	}
+
+	return strings.Join(r, "\n"), nil
+}
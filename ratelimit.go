@@ -0,0 +1,139 @@
+package main
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	rateLimitAnyCommand = "*"
+
+	msgRateLimited = "@%s , ***/%s*** is used too often on this pull request. " +
+		"The later ones will be ignored for a while, please try again later."
+
+	rateLimitSweepInterval = 10 * time.Minute
+)
+
+// tokenBucket holds the tokens of a user or a pr to use a command.
+// A token is refilled every interval up to the burst.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time
+
+	// acked means the user has been told that the command is limited.
+	acked bool
+}
+
+func (b *tokenBucket) refill(now time.Time, l *rateLimit) {
+	interval := time.Duration(l.Interval) * time.Second
+	burst := float64(l.Burst)
+
+	if b.last.IsZero() {
+		b.tokens = burst
+	} else if d := now.Sub(b.last); d > 0 {
+		b.tokens = math.Min(burst, b.tokens+float64(d)/float64(interval))
+	}
+
+	b.last = now
+	b.full = now.Add(time.Duration((burst - b.tokens) * float64(interval)))
+}
+
+// rateLimiter limits how often each user and each pr can use a command.
+// The buckets are kept in memory, so they are reset when the robot restarts.
+type rateLimiter struct {
+	lock      sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// take takes a token from each bucket of keys if all of them have one. Otherwise it returns false,
+// and notify is true for the first time the command is limited since the buckets had tokens.
+func (r *rateLimiter) take(keys []string, limits []*rateLimit, now time.Time) (ok, notify bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.sweep(now)
+
+	buckets := make([]*tokenBucket, len(keys))
+	ok = true
+
+	for i, k := range keys {
+		b, exists := r.buckets[k]
+		if !exists {
+			b = new(tokenBucket)
+			r.buckets[k] = b
+		}
+
+		b.refill(now, limits[i])
+
+		if b.tokens < 1 {
+			ok = false
+		}
+
+		buckets[i] = b
+	}
+
+	if ok {
+		for _, b := range buckets {
+			b.tokens--
+			b.acked = false
+		}
+
+		return true, false
+	}
+
+	for _, b := range buckets {
+		if b.tokens < 1 && !b.acked {
+			notify = true
+			b.acked = true
+		}
+	}
+
+	return false, notify
+}
+
+// sweep removes the buckets which have been refilled, since they are the same as new ones.
+func (r *rateLimiter) sweep(now time.Time) {
+	if now.Sub(r.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+
+	r.lastSweep = now
+
+	for k, b := range r.buckets {
+		if !b.full.After(now) {
+			delete(r.buckets, k)
+		}
+	}
+}
+
+// allow tells whether the commenter can use the command now according to the rate limits of config.
+func (r *rateLimiter) allow(ev *commandEvent, name string, cfg *botConfig) (ok, notify bool) {
+	l := cfg.RateLimits.get(name)
+	if l == nil {
+		return true, false
+	}
+
+	var keys []string
+	var limits []*rateLimit
+
+	if l.PerUser != nil {
+		keys = append(keys, "user:"+name+":"+ev.pr.Org+"/"+ev.pr.Repo+":"+ev.commenter)
+		limits = append(limits, l.PerUser)
+	}
+
+	if l.PerPR != nil {
+		keys = append(keys, "pr:"+name+":"+ev.pr.String())
+		limits = append(limits, l.PerPR)
+	}
+
+	return r.take(keys, limits, time.Now())
+}
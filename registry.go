@@ -0,0 +1,425 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	sdk "github.com/google/go-github/v36/github"
+	gc "github.com/opensourceways/robot-github-lib/client"
+	"github.com/opensourceways/server-common-lib/utils"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	msgHelp      = "@%s , these commands are available to you on this pull request:\n\n| command | description |\n| --- | --- |\n%s"
+	msgHelpEmpty = "@%s , no command is available to you on this pull request."
+
+	msgNoPermissionOfCommand = `***@%s*** has no permission to use ***/%s*** in this pull request. :astonished:
+It can be used by: %s.`
+
+	userTypeBot = "Bot"
+)
+
+// commandEvent is a comment on an open pull request and the commands in it.
+type commandEvent struct {
+	pr        gc.PRInfo
+	author    string
+	commenter string
+	isBot     bool
+	labels    sets.String
+	cmds      []command
+}
+
+func newCommandEventOfComment(e *sdk.IssueCommentEvent) *commandEvent {
+	org, repo := gc.GetOrgRepo(e.GetRepo())
+
+	labels := sets.NewString()
+	for _, l := range e.GetIssue().Labels {
+		labels.Insert(l.GetName())
+	}
+
+	return &commandEvent{
+		pr:        gc.PRInfo{Org: org, Repo: repo, Number: e.GetIssue().GetNumber()},
+		author:    e.GetIssue().GetUser().GetLogin(),
+		commenter: e.GetComment().GetUser().GetLogin(),
+		isBot:     e.GetComment().GetUser().GetType() == userTypeBot,
+		labels:    labels,
+		cmds:      parseCommands(e.GetComment().GetBody()),
+	}
+}
+
+func newCommandEventOfReview(e *sdk.PullRequestReviewEvent) *commandEvent {
+	org, repo := gc.GetOrgRepo(e.GetRepo())
+
+	labels := sets.NewString()
+	for _, l := range e.GetPullRequest().Labels {
+		labels.Insert(l.GetName())
+	}
+
+	return &commandEvent{
+		pr:        gc.PRInfo{Org: org, Repo: repo, Number: e.GetPullRequest().GetNumber()},
+		author:    e.GetPullRequest().GetUser().GetLogin(),
+		commenter: e.GetReview().GetUser().GetLogin(),
+		isBot:     e.GetReview().GetUser().GetType() == userTypeBot,
+		labels:    labels,
+		cmds:      parseCommands(e.GetReview().GetBody()),
+	}
+}
+
+func newCommandEventOfReviewComment(e *sdk.PullRequestReviewCommentEvent) *commandEvent {
+	org, repo := gc.GetOrgRepo(e.GetRepo())
+
+	labels := sets.NewString()
+	for _, l := range e.GetPullRequest().Labels {
+		labels.Insert(l.GetName())
+	}
+
+	return &commandEvent{
+		pr:        gc.PRInfo{Org: org, Repo: repo, Number: e.GetPullRequest().GetNumber()},
+		author:    e.GetPullRequest().GetUser().GetLogin(),
+		commenter: e.GetComment().GetUser().GetLogin(),
+		isBot:     e.GetComment().GetUser().GetType() == userTypeBot,
+		labels:    labels,
+		cmds:      parseCommands(e.GetComment().GetBody()),
+	}
+}
+
+type commandHandler func(bot *robot, ev *commandEvent, cmd command, cfg *botConfig, log *logrus.Entry) error
+
+type commandPrecheck func(bot *robot, ev *commandEvent, cmd command, cfg *botConfig, log *logrus.Entry) (string, error)
+
+// commandSpec declares a command which the robot handles.
+type commandSpec struct {
+	// name is the name of command without the leading slash.
+	name string
+
+	// args is the usage of the arguments, such as "[cancel]".
+	args string
+
+	// desc is the one-line description shown by /help.
+	desc string
+
+	// users returns the kinds of users who may use the command by default.
+	users func(cfg *botConfig) []string
+
+	// enabled tells whether the command is enabled by the config of repository.
+	// The command is always enabled if it is nil.
+	enabled func(cfg *botConfig) bool
+
+	// precheck returns the reason why the command can not be used on the pr by anyone.
+	// It is called after checking the permission and returns empty for the arguments
+	// which the command does not accept. It is skipped if it is nil.
+	precheck commandPrecheck
+
+	handle commandHandler
+}
+
+func (s *commandSpec) usage() string {
+	if s.args == "" {
+		return "/" + s.name
+	}
+
+	return "/" + s.name + " " + s.args
+}
+
+func (s *commandSpec) isEnabled(cfg *botConfig) bool {
+	return s.enabled == nil || s.enabled(cfg)
+}
+
+// allowedUsers returns the users who may use the command, which are specified by
+// the command permissions of config or are the default ones.
+func (s *commandSpec) allowedUsers(cfg *botConfig) *allowedUsers {
+	if p := cfg.CommandPermissions.get(s.name); p != nil {
+		return &allowedUsers{kinds: p.Roles, users: p.Users, teams: p.Teams}
+	}
+
+	return &allowedUsers{kinds: s.users(cfg)}
+}
+
+func fixedUsers(kinds ...string) func(*botConfig) []string {
+	return func(*botConfig) []string {
+		return kinds
+	}
+}
+
+// reviewerUsers includes the author who can only remove the lgtm label.
+func reviewerUsers(cfg *botConfig) []string {
+	if cfg.CheckPermissionBasedOnSigOwners {
+		return []string{userKindAuthor, userKindCollaborators, userKindSigOwners}
+	}
+
+	return []string{userKindAuthor, userKindCollaborators}
+}
+
+func approverUsers(cfg *botConfig) []string {
+	if cfg.ApproveBasedOnOwners {
+		return []string{userKindApprovers}
+	}
+
+	return []string{userKindCollaborators}
+}
+
+func holdUsers(cfg *botConfig) []string {
+	return cfg.HoldUsers
+}
+
+func ackEnabled(cfg *botConfig) bool {
+	return cfg.trailerProfile != nil && cfg.trailerProfile.EnableAck
+}
+
+func freezeEnabled(cfg *botConfig) bool {
+	return len(cfg.FreezeFile) > 0
+}
+
+// newCommandSpecs returns all the commands which the robot handles, in the order shown by /help.
+func newCommandSpecs() []commandSpec {
+	return []commandSpec{
+		{
+			name:   "lgtm",
+			args:   "[cancel]",
+			desc:   "Add or remove the lgtm label.",
+			users:  reviewerUsers,
+			handle: (*robot).handleLGTM,
+		},
+		{
+			name:   "approve",
+			args:   "[cancel]",
+			desc:   "Add or remove the approved label.",
+			users:  approverUsers,
+			handle: (*robot).handleApprove,
+		},
+		{
+			name:   "check-pr",
+			desc:   "Check the conditions of merging and merge the pr if they are met.",
+			users:  fixedUsers(userKindAnyone),
+			handle: (*robot).handleCheckPR,
+		},
+		{
+			name:   "rebase",
+			args:   "[cancel]",
+			desc:   "Add or remove the merge/rebase label which merges the pr by rebase.",
+			users:  fixedUsers(userKindCollaborators),
+			handle: (*robot).handleRebase,
+		},
+		{
+			name:   "squash",
+			args:   "[cancel]",
+			desc:   "Add or remove the merge/squash label which merges the pr by squash.",
+			users:  fixedUsers(userKindCollaborators),
+			handle: (*robot).handleFlattened,
+		},
+		{
+			name:   "cla",
+			args:   "cancel",
+			desc:   "Remove the label of the signed cla.",
+			users:  fixedUsers(userKindCollaborators),
+			handle: (*robot).removeInvalidCLA,
+		},
+		{
+			name:    "ack",
+			desc:    "Add the Acked-by trailer of you to the commit message.",
+			users:   fixedUsers(userKindCollaborators),
+			enabled: ackEnabled,
+			handle:  (*robot).handleACK,
+		},
+		{
+			name:     "freeze-exception",
+			args:     "[cancel]",
+			desc:     "Allow or disallow the pr to be merged while its target branch is frozen.",
+			users:    fixedUsers(userKindFreezeOwners),
+			enabled:  freezeEnabled,
+			precheck: (*robot).precheckFreezeException,
+			handle:   (*robot).handleFreezeException,
+		},
+		{
+			name:    "freeze-status",
+			desc:    "Show whether the target branch is frozen and when the freeze lifts.",
+			users:   fixedUsers(userKindAnyone),
+			enabled: freezeEnabled,
+			handle:  (*robot).handleFreezeStatus,
+		},
+		{
+			name:   "hold",
+			args:   "[cancel]",
+			desc:   "Add or remove the do-not-merge/hold label which blocks merging.",
+			users:  holdUsers,
+			handle: (*robot).handleHold,
+		},
+		{
+			name:   "unhold",
+			desc:   "Remove the do-not-merge/hold label.",
+			users:  holdUsers,
+			handle: (*robot).handleHold,
+		},
+		{
+			name:   assignCommand,
+			args:   "[@user ...]",
+			desc:   "Assign the users or yourself to the pr.",
+			users:  fixedUsers(userKindAnyone),
+			handle: (*robot).handleAssign,
+		},
+		{
+			name:   unassignCommand,
+			args:   "[@user ...]",
+			desc:   "Unassign the users or yourself from the pr.",
+			users:  fixedUsers(userKindAnyone),
+			handle: (*robot).handleAssign,
+		},
+		{
+			name:   ccCommand,
+			args:   "[@user ...]",
+			desc:   "Request the users or yourself to review the pr.",
+			users:  fixedUsers(userKindAnyone),
+			handle: (*robot).handleAssign,
+		},
+		{
+			name:   "permissions",
+			args:   "[@user]",
+			desc:   "Show the permissions of the user or yourself on the pr.",
+			users:  fixedUsers(userKindAnyone),
+			handle: (*robot).handlePermissions,
+		},
+		{
+			name:   "help",
+			desc:   "Show the commands available to you.",
+			users:  fixedUsers(userKindAnyone),
+			handle: (*robot).handleHelp,
+		},
+	}
+}
+
+// isRateLimited tells whether the command exceeds its rate limit, and acknowledges it when
+// configured. The commands built from the state of review are not typed, so they are not limited.
+func (bot *robot) isRateLimited(ev *commandEvent, cmd command, cfg *botConfig, log *logrus.Entry) (bool, error) {
+	if cmd.fromReview {
+		return false, nil
+	}
+
+	ok, notify := bot.limiter.allow(ev, cmd.name, cfg)
+	if ok {
+		return false, nil
+	}
+
+	log.Infof("/%s of %s is rate limited", cmd.name, ev.commenter)
+
+	if !notify || !cfg.RateLimits.Acknowledge {
+		return true, nil
+	}
+
+	return true, bot.cli.CreatePRComment(ev.pr, fmt.Sprintf(msgRateLimited, ev.commenter, cmd.name))
+}
+
+func (bot *robot) findCommand(name string) *commandSpec {
+	for i := range bot.commands {
+		if bot.commands[i].name == name {
+			return &bot.commands[i]
+		}
+	}
+
+	return nil
+}
+
+// dispatchCommands runs the handler of each command in the comment if the commenter
+// may use it. The same command repeated in one comment is handled once, and the
+// comments of denied users are ignored.
+func (bot *robot) dispatchCommands(ev *commandEvent, cfg *botConfig, log *logrus.Entry) error {
+	if cfg.CommandPermissions.isDenied(ev.commenter, ev.isBot) {
+		return nil
+	}
+
+	checker := bot.newPermissionChecker(ev, cfg, log)
+	done := sets.NewString()
+	merr := utils.NewMultiErrors()
+
+	for _, cmd := range ev.cmds {
+		spec := bot.findCommand(cmd.name)
+		if spec == nil || !spec.isEnabled(cfg) {
+			continue
+		}
+
+		key := cmd.name + " " + strings.ToLower(strings.Join(cmd.args, " "))
+		if done.Has(key) {
+			continue
+		}
+		done.Insert(key)
+
+		// it is checked before the permission, so the unauthorized attempts are limited too.
+		if limited, err := bot.isRateLimited(ev, cmd, cfg, log); limited {
+			if err != nil {
+				merr.AddError(err)
+			}
+
+			continue
+		}
+
+		allowed := spec.allowedUsers(cfg)
+
+		b, err := checker.isAllowed(allowed)
+		if err != nil {
+			merr.AddError(err)
+
+			continue
+		}
+
+		// the precheck explains why no one can use the command, which is the reason of
+		// a denial too, so the comment replaces the one of no permission.
+		reason := ""
+		if spec.precheck != nil {
+			if reason, err = spec.precheck(bot, ev, cmd, cfg, log); err != nil {
+				merr.AddError(err)
+
+				continue
+			}
+		}
+
+		if reason == "" && !b {
+			reason = fmt.Sprintf(msgNoPermissionOfCommand, ev.commenter, cmd.name, allowed)
+		}
+
+		if reason != "" {
+			if err := bot.cli.CreatePRComment(ev.pr, reason); err != nil {
+				merr.AddError(err)
+			}
+
+			continue
+		}
+
+		if err := spec.handle(bot, ev, cmd, cfg, log.WithField("command", cmd.name)); err != nil {
+			merr.AddError(err)
+		}
+	}
+
+	return merr.Err()
+}
+
+func (bot *robot) handleHelp(ev *commandEvent, cmd command, cfg *botConfig, log *logrus.Entry) error {
+	if len(cmd.args) > 0 {
+		return nil
+	}
+
+	checker := bot.newPermissionChecker(ev, cfg, log)
+
+	var rows []string
+	for i := range bot.commands {
+		spec := &bot.commands[i]
+		if !spec.isEnabled(cfg) {
+			continue
+		}
+
+		b, err := checker.isAllowed(spec.allowedUsers(cfg))
+		if err != nil {
+			log.WithError(err).Errorf("check the permission of /%s", spec.name)
+		}
+
+		if b {
+			rows = append(rows, fmt.Sprintf("| %s | %s |", spec.usage(), spec.desc))
+		}
+	}
+
+	if len(rows) == 0 {
+		return bot.cli.CreatePRComment(ev.pr, fmt.Sprintf(msgHelpEmpty, ev.commenter))
+	}
+
+	return bot.cli.CreatePRComment(ev.pr, fmt.Sprintf(msgHelp, ev.commenter, strings.Join(rows, "\n")))
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	sdk "github.com/google/go-github/v36/github"
+	gc "github.com/opensourceways/robot-github-lib/client"
+	"github.com/opensourceways/server-common-lib/config"
+	"github.com/opensourceways/server-common-lib/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	reviewStateApproved         = "approved"
+	reviewStateChangesRequested = "changes_requested"
+	reviewStateDismissed        = "dismissed"
+	eventReviewDismissed        = "review_dismissed"
+	reviewSubmitted             = "submitted"
+	reviewDismissed             = "dismissed"
+	reviewCommentCreated        = "created"
+
+	// changesRequestedLabel is added when an eligible reviewer requests changes
+	// in the review UI of github. It blocks merging until each of these reviewers
+	// approves or has the review dismissed, or new commits are pushed.
+	changesRequestedLabel = "changes-requested"
+
+	commentChangesRequested = `***%s*** requested changes on this pull request and ***%s*** was added. :flushed:
+The pull request can not be merged until the reviewer approves, the review is dismissed or new code changes are pushed.`
+	commentApprovalDismissed = `The approval of ***%s*** was dismissed and ***%s*** was removed. :flushed: `
+	msgChangesRequested      = "PR has been requested changes by reviewers and it has the label: %s"
+)
+
+func (bot *robot) handleReviewEvent(e *sdk.PullRequestReviewEvent, pc config.Config, log *logrus.Entry) error {
+	if e.GetPullRequest().GetState() != open {
+		return nil
+	}
+
+	org, repo := gc.GetOrgRepo(e.GetRepo())
+	cfg, err := bot.getConfig(pc, org, repo)
+	if err != nil {
+		return err
+	}
+
+	ev := newCommandEventOfReview(e)
+
+	switch e.GetAction() {
+	case reviewDismissed:
+		return bot.dismissReview(ev, e.GetReview().GetID(), cfg, log)
+
+	case reviewSubmitted:
+		state := strings.ToLower(e.GetReview().GetState())
+
+		// the approval works as /lgtm, so it is checked by the permission of /lgtm.
+		// The commands in the body of review are handled as the ones of comment.
+		if state == reviewStateApproved {
+			ev.cmds = append([]command{{name: cmdAddLgtm.name, fromReview: true}}, ev.cmds...)
+		}
+
+		merr := utils.NewMultiErrors()
+
+		switch state {
+		case reviewStateChangesRequested:
+			if err := bot.requestChanges(ev, cfg, log); err != nil {
+				merr.AddError(err)
+			}
+
+		case reviewStateApproved:
+			if _, err := bot.clearChangesRequested(ev, cfg, log); err != nil {
+				merr.AddError(err)
+			}
+		}
+
+		if err := bot.dispatchCommands(ev, cfg, log); err != nil {
+			merr.AddError(err)
+		}
+
+		return merr.Err()
+	}
+
+	return nil
+}
+
+// handleReviewCommentEvent handles the commands in the inline review comments of pr.
+func (bot *robot) handleReviewCommentEvent(e *sdk.PullRequestReviewCommentEvent, pc config.Config, log *logrus.Entry) error {
+	if e.GetAction() != reviewCommentCreated || e.GetPullRequest().GetState() != open {
+		return nil
+	}
+
+	org, repo := gc.GetOrgRepo(e.GetRepo())
+	cfg, err := bot.getConfig(pc, org, repo)
+	if err != nil {
+		return err
+	}
+
+	return bot.dispatchCommands(newCommandEventOfReviewComment(e), cfg, log)
+}
+
+// requestChanges adds the changes-requested label if the reviewer can use /lgtm.
+func (bot *robot) requestChanges(ev *commandEvent, cfg *botConfig, log *logrus.Entry) error {
+	pr, reviewer := ev.pr, ev.commenter
+	if ev.author == reviewer || cfg.CommandPermissions.isDenied(reviewer, ev.isBot) {
+		return nil
+	}
+
+	v, err := bot.newPermissionChecker(ev, cfg, log).isAllowed(
+		bot.findCommand(cmdAddLgtm.name).allowedUsers(cfg),
+	)
+	if err != nil {
+		return err
+	}
+	if !v {
+		log.Infof("%s has no permission to request changes", reviewer)
+
+		return nil
+	}
+
+	if err := bot.createLabelIfNeed(pr.Org, pr.Repo, changesRequestedLabel); err != nil {
+		log.WithError(err).Errorf("create repo label: %s", changesRequestedLabel)
+	}
+
+	if err := bot.cli.AddPRLabel(pr, changesRequestedLabel); err != nil {
+		return err
+	}
+
+	return bot.cli.CreatePRComment(
+		pr, fmt.Sprintf(commentChangesRequested, reviewer, changesRequestedLabel),
+	)
+}
+
+// dismissReview withdraws what the dismissed review did. The lgtm label of reviewer is removed
+// if it was an approval, and the changes-requested label is removed if no one requests changes.
+func (bot *robot) dismissReview(ev *commandEvent, reviewID int64, cfg *botConfig, log *logrus.Entry) error {
+	pr := ev.pr
+	merr := utils.NewMultiErrors()
+
+	state, err := bot.getDismissedReviewState(pr, reviewID)
+	if err != nil {
+		merr.AddError(err)
+	}
+
+	if state == reviewStateApproved && ev.commenter != ev.author {
+		if l := genLGTMLabel(ev.commenter, cfg.LgtmCountsRequired); ev.labels.Has(l) {
+			if err := bot.cli.RemovePRLabel(pr, l); err != nil {
+				merr.AddError(err)
+			} else if err := bot.cli.CreatePRComment(
+				pr, fmt.Sprintf(commentApprovalDismissed, ev.commenter, l),
+			); err != nil {
+				merr.AddError(err)
+			}
+		}
+	}
+
+	removed, err := bot.clearChangesRequested(ev, cfg, log)
+	if err != nil {
+		merr.AddError(err)
+	}
+
+	if removed {
+		if err := bot.tryMerge(pr, "", cfg, false, log); err != nil {
+			merr.AddError(err)
+		}
+	}
+
+	return merr.Err()
+}
+
+// getDismissedReviewState returns the state of review before it was dismissed,
+// since the review itself only shows that it is dismissed.
+func (bot *robot) getDismissedReviewState(pr gc.PRInfo, reviewID int64) (string, error) {
+	events, err := bot.cli.ListIssueEvents(pr)
+	if err != nil {
+		return "", err
+	}
+
+	for _, e := range events {
+		if e.GetEvent() == eventReviewDismissed && e.GetDismissedReview().GetReviewID() == reviewID {
+			return strings.ToLower(e.GetDismissedReview().GetState()), nil
+		}
+	}
+
+	return "", nil
+}
+
+// clearChangesRequested removes the changes-requested label if none of the eligible reviewers
+// still requests changes, that is each of them has approved or has the review dismissed since.
+func (bot *robot) clearChangesRequested(ev *commandEvent, cfg *botConfig, log *logrus.Entry) (bool, error) {
+	if !ev.labels.Has(changesRequestedLabel) {
+		return false, nil
+	}
+
+	v, err := bot.getChangesRequesters(ev, cfg, log)
+	if err != nil || len(v) > 0 {
+		return false, err
+	}
+
+	if err := bot.cli.RemovePRLabel(ev.pr, changesRequestedLabel); err != nil {
+		return false, err
+	}
+
+	ev.labels.Delete(changesRequestedLabel)
+
+	return true, nil
+}
+
+// getChangesRequesters returns the reviewers whose latest review requests changes
+// and who may request changes.
+func (bot *robot) getChangesRequesters(ev *commandEvent, cfg *botConfig, log *logrus.Entry) ([]string, error) {
+	reviews, err := bot.cli.ListReviews(ev.pr)
+	if err != nil {
+		return nil, err
+	}
+
+	latest := make(map[string]*sdk.PullRequestReview)
+	for _, r := range reviews {
+		switch strings.ToLower(r.GetState()) {
+		case reviewStateApproved, reviewStateChangesRequested, reviewStateDismissed:
+			latest[r.GetUser().GetLogin()] = r
+		}
+	}
+
+	allowed := bot.findCommand(cmdAddLgtm.name).allowedUsers(cfg)
+
+	var r []string
+	for login, review := range latest {
+		if strings.ToLower(review.GetState()) != reviewStateChangesRequested || login == ev.author {
+			continue
+		}
+
+		reviewer := *ev
+		reviewer.commenter = login
+		reviewer.isBot = review.GetUser().GetType() == userTypeBot
+
+		if cfg.CommandPermissions.isDenied(login, reviewer.isBot) {
+			continue
+		}
+
+		b, err := bot.newPermissionChecker(&reviewer, cfg, log).isAllowed(allowed)
+		if err != nil {
+			return nil, err
+		}
+
+		if b {
+			r = append(r, login)
+		}
+	}
+
+	return r, nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	sdk "github.com/google/go-github/v36/github"
+	gc "github.com/opensourceways/robot-github-lib/client"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	reviewerModeNotify  = "notify"
+	reviewerModeSuggest = "suggest"
+	reviewerModeAssign  = "assign"
+
+	actionReopened       = "reopened"
+	actionReadyForReview = "ready_for_review"
+
+	msgSuggestReviewers = "**@%s** Thank you for submitting a PullRequest. It is detected that you have not set a reviewer. " +
+		"These reviewers are suggested according to the owners of the changed files, " +
+		"comment the command below to assign them:\n\n`/assign %s`"
+	msgReviewersAssigned = "**@%s** Thank you for submitting a PullRequest. These reviewers were assigned " +
+		"according to the owners of the changed files: %s"
+)
+
+// assignReviewers suggests or assigns the reviewers for the pr which has not been set reviewers.
+// It returns false if there is no candidate.
+func (bot *robot) assignReviewers(pr *sdk.PullRequest, p gc.PRInfo, cfg *botConfig, log *logrus.Entry) (bool, error) {
+	ra := &cfg.ReviewerAssignment
+
+	candidates, err := bot.candidateReviewers(pr, p, cfg, log)
+	if err != nil || len(candidates) == 0 {
+		return false, err
+	}
+
+	reviewers, err := bot.balanceReviewers(p, candidates, ra.Count)
+	if err != nil {
+		return false, err
+	}
+
+	logins := make([]string, len(reviewers))
+	for i := range reviewers {
+		logins[i] = "@" + reviewers[i]
+	}
+
+	author := pr.GetUser().GetLogin()
+
+	if ra.Mode == reviewerModeAssign {
+		if err := bot.cli.AssignPR(p, reviewers); err != nil {
+			return false, err
+		}
+
+		return true, bot.cli.CreatePRComment(p, fmt.Sprintf(
+			msgReviewersAssigned, author, strings.Join(logins, ", "),
+		))
+	}
+
+	// the command is shown as code, otherwise the robot would handle it in its own comment.
+	return true, bot.cli.CreatePRComment(p, fmt.Sprintf(
+		msgSuggestReviewers, author, strings.Join(logins, " "),
+	))
+}
+
+// isReadyToReview tells whether the pr becomes ready to review by the action of pull request event.
+func isReadyToReview(action string) bool {
+	return action == actionOpened || action == actionReopened || action == actionReadyForReview
+}
+
+// candidateReviewers returns the users who are declared by the OWNERS files of the changed files,
+// the owners of the sigs of the changed files, and the maintainers and committers of the sig
+// which the repository belongs to. The author is excluded.
+func (bot *robot) candidateReviewers(pr *sdk.PullRequest, p gc.PRInfo, cfg *botConfig, log *logrus.Entry) ([]string, error) {
+	files, err := getChangedFiles(bot.cli, p)
+	if err != nil {
+		return nil, err
+	}
+
+	owners, err := bot.cache.getRepoOwners(p.Org, p.Repo, pr.GetBase().GetRef(), log)
+	if err != nil {
+		return nil, err
+	}
+
+	r := sets.NewString()
+	for _, f := range files {
+		r = r.Union(owners.reviewersOf(f))
+	}
+
+	if cfg.CheckPermissionBasedOnSigOwners {
+		sigs, err := bot.cache.getSigOwners(p.Org, p.Repo, "master", cfg, log)
+		if err != nil {
+			log.WithError(err).Error("get owners of sigs")
+		}
+
+		for _, f := range files {
+			if v, ok := sigs[path.Dir(f)]; ok {
+				r = r.Union(v)
+			}
+		}
+	}
+
+	if c := cfg.ReviewerAssignment.Community; c != nil {
+		members := bot.cache.getSigMembersOfRepos(c, log)
+		if v, ok := members[p.Org+"/"+p.Repo]; ok {
+			r = r.Union(v.all())
+		}
+	}
+
+	r.Delete(strings.ToLower(pr.GetUser().GetLogin()))
+
+	return r.List(), nil
+}
+
+// balanceReviewers chooses n candidates who have the least open prs to review in the repository.
+func (bot *robot) balanceReviewers(p gc.PRInfo, candidates []string, n int) ([]string, error) {
+	prs, err := bot.cli.GetPullRequests(gc.PRInfo{Org: p.Org, Repo: p.Repo})
+	if err != nil {
+		return nil, err
+	}
+
+	load := make(map[string]int)
+	for _, v := range prs {
+		if v.GetState() != open || v.GetNumber() == p.Number {
+			continue
+		}
+
+		reviewers := sets.NewString()
+		for _, u := range v.Assignees {
+			reviewers.Insert(strings.ToLower(u.GetLogin()))
+		}
+
+		for _, u := range v.RequestedReviewers {
+			reviewers.Insert(strings.ToLower(u.GetLogin()))
+		}
+
+		for u := range reviewers {
+			load[u]++
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return load[candidates[i]] < load[candidates[j]]
+	})
+
+	if len(candidates) > n {
+		candidates = candidates[:n]
+	}
+
+	return candidates, nil
+}
@@ -30,15 +30,43 @@ type iClient interface {
 	GetDirectoryTree(org, repo, branch string, recursive bool) ([]*sdk.TreeEntry, error)
 	GetSinglePR(org, repo string, number int) (*sdk.PullRequest, error)
 	GetPullRequests(pr gc.PRInfo) ([]*sdk.PullRequest, error)
+	GetPRCommits(pr gc.PRInfo) ([]*sdk.RepositoryCommit, error)
+	IsCollaborator(pr gc.PRInfo, login string) (bool, error)
+	AssignPR(pr gc.PRInfo, logins []string) error
+	UnAssignPR(pr gc.PRInfo, logins []string) error
+	RequestReviewers(pr gc.PRInfo, logins []string) error
+	CompareCommits(org, repo, base, head string) (*sdk.CommitsComparison, error)
+	UpdatePRComment(pr gc.PRInfo, commentID int64, ic *sdk.IssueComment) error
+	IsTeamMember(org, team, login string) (bool, error)
+	ListReviews(pr gc.PRInfo) ([]*sdk.PullRequestReview, error)
+	ListIssueEvents(pr gc.PRInfo) ([]*sdk.IssueEvent, error)
+	ListReviewComments(pr gc.PRInfo) ([]*sdk.PullRequestComment, error)
+	GetBot() (string, error)
+	GetUser(login string) (*sdk.User, error)
 }
 
-func newRobot(cli iClient, cacheCli *cache.SDK) *robot {
-	return &robot{cli: cli, cacheCli: cacheCli}
+func newRobot(cli iClient, cacheCli *cache.SDK, botLogin string) *robot {
+	return &robot{
+		cli:      cli,
+		botLogin: botLogin,
+		cache:    newFileCache(cacheCli, cli),
+		queue:    newMergeQueue(),
+		retries:  newFreezeRetries(),
+		commands: newCommandSpecs(),
+		limiter:  newRateLimiter(),
+	}
 }
 
 type robot struct {
 	cli      iClient
-	cacheCli *cache.SDK
+	cache    *fileCache
+	queue    *mergeQueue
+	retries  *freezeRetries
+	commands []commandSpec
+	limiter  *rateLimiter
+
+	// botLogin is the login of the robot, whose comments are trusted.
+	botLogin string
 }
 
 func (bot *robot) NewConfig() config.Config {
@@ -61,6 +89,9 @@ func (bot *robot) getConfig(cfg config.Config, org, repo string) (*botConfig, er
 func (bot *robot) RegisterEventHandler(p framework.HandlerRegister) {
 	p.RegisterPullRequestHandler(bot.handlePREvent)
 	p.RegisterIssueCommentHandler(bot.handleCommentEvent)
+	p.RegisterReviewEventHandler(bot.handleReviewEvent)
+	p.RegisterReviewCommentEventHandler(bot.handleReviewCommentEvent)
+	p.RegisterStatusEventHandler(bot.handleStatusEvent)
 }
 
 func (bot *robot) handlePREvent(e *sdk.PullRequestEvent, pc config.Config, log *logrus.Entry) error {
@@ -72,7 +103,7 @@ func (bot *robot) handlePREvent(e *sdk.PullRequestEvent, pc config.Config, log *
 	pr := gc.PRInfo{Org: org, Repo: repo, Number: e.GetNumber()}
 
 	merr := utils.NewMultiErrors()
-	if err := bot.clearLabel(e, pr); err != nil {
+	if err := bot.clearLabel(e, pr, cfg, log); err != nil {
 		merr.AddError(err)
 	}
 
@@ -80,60 +111,55 @@ func (bot *robot) handlePREvent(e *sdk.PullRequestEvent, pc config.Config, log *
 		merr.AddError(err)
 	}
 
-	if err := bot.checkReviewer(e, pr, cfg); err != nil {
+	if err := bot.postInterdiff(e, pr, log); err != nil {
 		merr.AddError(err)
 	}
 
-	if err := bot.handleLabelUpdate(e, pr, cfg, log); err != nil {
+	if err := bot.checkReviewer(e, pr, cfg, log); err != nil {
 		merr.AddError(err)
 	}
 
-	return merr.Err()
-}
-
-func (bot *robot) handleCommentEvent(e *sdk.IssueCommentEvent, pc config.Config, log *logrus.Entry) error {
-	org, repo := gc.GetOrgRepo(e.GetRepo())
-	cfg, err := bot.getConfig(pc, org, repo)
-	if err != nil {
-		return err
-	}
-
-	merr := utils.NewMultiErrors()
-	if err := bot.handleLGTM(e, cfg, log); err != nil {
+	if err := bot.handleLabelUpdate(e, pr, cfg, log); err != nil {
 		merr.AddError(err)
 	}
 
-	if err = bot.handleApprove(e, cfg, log); err != nil {
+	if err := bot.notifyFrozenBranch(e, pr, cfg, log); err != nil {
 		merr.AddError(err)
 	}
 
-	if err = bot.handleCheckPR(e, cfg, log); err != nil {
+	if err := bot.handleHoldRemoved(e, pr, cfg, log); err != nil {
 		merr.AddError(err)
 	}
 
-	if err = bot.removeInvalidCLA(e, cfg, log); err != nil {
-		merr.AddError(err)
-	}
+	return merr.Err()
+}
 
-	if err = bot.handleRebase(e, cfg, log); err != nil {
-		merr.AddError(err)
+func (bot *robot) handleCommentEvent(e *sdk.IssueCommentEvent, pc config.Config, log *logrus.Entry) error {
+	if !e.GetIssue().IsPullRequest() ||
+		e.GetIssue().GetState() != open ||
+		!gc.IsCommentCreated(e) {
+		return nil
 	}
 
-	if err = bot.handleFlattened(e, cfg, log); err != nil {
-		merr.AddError(err)
+	org, repo := gc.GetOrgRepo(e.GetRepo())
+	cfg, err := bot.getConfig(pc, org, repo)
+	if err != nil {
+		return err
 	}
 
-	if err = bot.removeRebase(e, cfg, log); err != nil {
-		merr.AddError(err)
-	}
+	return bot.dispatchCommands(newCommandEventOfComment(e), cfg, log)
+}
 
-	if err = bot.removeFlattened(e, cfg, log); err != nil {
-		merr.AddError(err)
+func (bot *robot) handleStatusEvent(e *sdk.StatusEvent, pc config.Config, log *logrus.Entry) error {
+	if e.GetState() != statusSuccess {
+		return nil
 	}
 
-	if err = bot.handleACK(e, cfg, log); err != nil {
-		merr.AddError(err)
+	org, repo := gc.GetOrgRepo(e.GetRepo())
+	cfg, err := bot.getConfig(pc, org, repo)
+	if err != nil {
+		return err
 	}
 
-	return merr.Err()
+	return bot.tryMergeBySHA(org, repo, e.GetSHA(), cfg, log)
 }
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	sdk "github.com/google/go-github/v36/github"
+	gc "github.com/opensourceways/robot-github-lib/client"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	reasonOutOfScope      = "the changed files are out of the scope of %s"
+	reasonInScope         = "@%s is responsible for the changed files: %s"
+	reasonNoScope         = "@%s is not limited to any files by OWNERS or sig"
+	reasonUnknownReviewer = "the reviewer who added it is unknown"
+	reasonNewChanges      = "new code changes are pushed"
+
+	commentScopedClearLabel = "New code changes of pr are detected and the labels are checked against the scope of their reviewers.\n%s"
+)
+
+// labelDecision tells whether a label is kept after the push and why.
+type labelDecision struct {
+	label  string
+	keep   bool
+	reason string
+}
+
+func (d labelDecision) String() string {
+	if d.keep {
+		return fmt.Sprintf("- ***%s*** is kept, %s.", d.label, d.reason)
+	}
+
+	return fmt.Sprintf("- ***%s*** is removed, %s.", d.label, d.reason)
+}
+
+// reviewScope holds the files each reviewer is responsible for according to
+// the OWNERS files and the owners of sigs.
+type reviewScope struct {
+	files  []string
+	owners repoOwners
+	sigs   map[string]sets.String
+}
+
+func (s *reviewScope) isResponsible(login, file string) bool {
+	if s.owners.reviewersOf(file).Has(login) {
+		return true
+	}
+
+	v, ok := s.sigs[path.Dir(file)]
+
+	return ok && v.Has(login)
+}
+
+// check tells whether the approval of reviewer can be kept after the changed files are pushed.
+// The reviewer who is not responsible for any file of pr is regarded as responsible for all.
+func (s *reviewScope) check(login string, changed []string) (bool, string) {
+	hasScope := false
+	for _, f := range s.files {
+		if s.isResponsible(login, f) {
+			hasScope = true
+
+			break
+		}
+	}
+
+	if !hasScope {
+		return false, fmt.Sprintf(reasonNoScope, login)
+	}
+
+	var hits []string
+	for _, f := range changed {
+		if s.isResponsible(login, f) {
+			hits = append(hits, f)
+		}
+	}
+
+	if len(hits) > 0 {
+		return false, fmt.Sprintf(reasonInScope, login, strings.Join(hits, ", "))
+	}
+
+	return true, fmt.Sprintf(reasonOutOfScope, "@"+login)
+}
+
+// decideLabelsByScope decides whether each lgtm and approved label is kept after the push.
+// The lgtm-login label belongs to its reviewer, and the lgtm or approved label shared by
+// several reviewers is kept only if all of them can keep their approvals.
+func (bot *robot) decideLabelsByScope(
+	pr *sdk.PullRequest, p gc.PRInfo, cfg *botConfig, labels, changed []string, log *logrus.Entry,
+) ([]labelDecision, error) {
+	scope, err := bot.loadReviewScope(pr, p, cfg, log)
+	if err != nil {
+		return nil, err
+	}
+
+	comments, err := listPRComments(bot.cli, p)
+	if err != nil {
+		return nil, err
+	}
+
+	author := pr.GetUser().GetLogin()
+	lgtmUsers := getCommandUsers(comments, author, cmdAddLgtm, cmdRemoveLgtm)
+
+	checkAll := func(label string, users sets.String) labelDecision {
+		if users.Len() == 0 {
+			return labelDecision{label: label, reason: reasonUnknownReviewer}
+		}
+
+		var reasons []string
+		for _, u := range users.List() {
+			keep, reason := scope.check(u, changed)
+			if !keep {
+				return labelDecision{label: label, reason: reason}
+			}
+
+			reasons = append(reasons, "@"+u)
+		}
+
+		return labelDecision{
+			label:  label,
+			keep:   true,
+			reason: fmt.Sprintf(reasonOutOfScope, strings.Join(reasons, ", ")),
+		}
+	}
+
+	r := make([]labelDecision, 0, len(labels))
+
+	for _, label := range labels {
+		switch {
+		case label == approvedLabel:
+			r = append(r, checkAll(label, getApproversFromComments(comments, author)))
+
+		case label == lgtmLabel:
+			r = append(r, checkAll(label, lgtmUsers))
+
+		default:
+			users := sets.NewString()
+			for u := range lgtmUsers {
+				if genLGTMLabel(u, cfg.LgtmCountsRequired) == label {
+					users.Insert(u)
+				}
+			}
+
+			r = append(r, checkAll(label, users))
+		}
+	}
+
+	return r, nil
+}
+
+func (bot *robot) loadReviewScope(pr *sdk.PullRequest, p gc.PRInfo, cfg *botConfig, log *logrus.Entry) (*reviewScope, error) {
+	owners, err := bot.cache.getRepoOwners(p.Org, p.Repo, pr.GetBase().GetRef(), log)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := getChangedFiles(bot.cli, p)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &reviewScope{
+		files:  files,
+		owners: owners,
+	}
+
+	if cfg.CheckPermissionBasedOnSigOwners {
+		sigs, err := bot.cache.getSigOwners(p.Org, p.Repo, "master", cfg, log)
+		if err != nil {
+			log.WithError(err).Error("get owners of sigs")
+		}
+
+		s.sigs = sigs
+	}
+
+	return s, nil
+}
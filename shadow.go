@@ -0,0 +1,85 @@
+package main
+
+import (
+	sdk "github.com/google/go-github/v36/github"
+	gc "github.com/opensourceways/robot-github-lib/client"
+	"github.com/sirupsen/logrus"
+)
+
+// shadowClient handles the read calls normally, but only logs the write calls
+// as the intended actions of robot. It is used to evaluate the config of robot
+// without affecting the repositories.
+type shadowClient struct {
+	iClient
+
+	log *logrus.Entry
+}
+
+func newShadowClient(cli iClient) iClient {
+	return shadowClient{
+		iClient: cli,
+		log:     logrus.WithField("mode", "shadow"),
+	}
+}
+
+func (c shadowClient) record(action string, fields logrus.Fields) {
+	c.log.WithFields(fields).WithField("intended_action", action).Info()
+}
+
+func (c shadowClient) AddPRLabel(pr gc.PRInfo, label string) error {
+	c.record("add_pr_label", logrus.Fields{"pr": pr.String(), "label": label})
+
+	return nil
+}
+
+func (c shadowClient) RemovePRLabel(pr gc.PRInfo, label string) error {
+	c.record("remove_pr_label", logrus.Fields{"pr": pr.String(), "label": label})
+
+	return nil
+}
+
+func (c shadowClient) CreatePRComment(pr gc.PRInfo, comment string) error {
+	c.record("create_pr_comment", logrus.Fields{"pr": pr.String(), "comment": comment})
+
+	return nil
+}
+
+func (c shadowClient) MergePR(pr gc.PRInfo, commitMessage string, opt *sdk.PullRequestOptions) error {
+	c.record("merge_pr", logrus.Fields{
+		"pr":             pr.String(),
+		"commit_message": commitMessage,
+		"merge_method":   opt.MergeMethod,
+	})
+
+	return nil
+}
+
+func (c shadowClient) CreateRepoLabel(org, repo, label string) error {
+	c.record("create_repo_label", logrus.Fields{"org": org, "repo": repo, "label": label})
+
+	return nil
+}
+
+func (c shadowClient) AssignPR(pr gc.PRInfo, logins []string) error {
+	c.record("assign_pr", logrus.Fields{"pr": pr.String(), "logins": logins})
+
+	return nil
+}
+
+func (c shadowClient) UnAssignPR(pr gc.PRInfo, logins []string) error {
+	c.record("unassign_pr", logrus.Fields{"pr": pr.String(), "logins": logins})
+
+	return nil
+}
+
+func (c shadowClient) RequestReviewers(pr gc.PRInfo, logins []string) error {
+	c.record("request_reviewers", logrus.Fields{"pr": pr.String(), "logins": logins})
+
+	return nil
+}
+
+func (c shadowClient) UpdatePRComment(pr gc.PRInfo, commentID int64, ic *sdk.IssueComment) error {
+	c.record("update_pr_comment", logrus.Fields{"pr": pr.String(), "comment_id": commentID, "comment": ic.GetBody()})
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	builtinTrailerProfile = "kernel"
+
+	// builtinTrailerProfileRepo uses the builtin profile if no profile is selected for it.
+	builtinTrailerProfileRepo = "openeuler/kernel"
+)
+
+// trailerProfile decides the trailers in the commit message when merging PR,
+// such as Reviewed-by, Signed-off-by and Acked-by with the name and email of reviewers.
+type trailerProfile struct {
+	Name string `json:"name" required:"true"`
+
+	// EnableAck enables the /ack command and the Acked-by trailer.
+	EnableAck bool `json:"enable_ack,omitempty"`
+
+	// CountEditedComments means the edited /lgtm, /approve and /ack comments are counted as well.
+	CountEditedComments bool `json:"count_edited_comments,omitempty"`
+
+	// IdentityFile is the sig-info.yaml in which the name and email of reviewers are found.
+	// The reviewers not in it will not be listed in the trailers.
+	IdentityFile *repoFile `json:"identity_file,omitempty"`
+
+	// UseConfiguredMergeMethod means PR is always merged by the merge_method of repository config,
+	// and the merge labels and the repository yaml are ignored.
+	UseConfiguredMergeMethod bool `json:"use_configured_merge_method,omitempty"`
+
+	// SyncBot is the login of the robot which syncs PR from other repository.
+	// The body of its PR is rewritten with the original PR and the body of related PR.
+	SyncBot string `json:"sync_bot,omitempty"`
+}
+
+func (p *trailerProfile) validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("missing name of trailer profile")
+	}
+
+	if p.IdentityFile != nil {
+		return p.IdentityFile.validate()
+	}
+
+	return nil
+}
+
+func defaultTrailerProfiles() map[string]trailerProfile {
+	return map[string]trailerProfile{
+		builtinTrailerProfile: {
+			Name:                     builtinTrailerProfile,
+			EnableAck:                true,
+			CountEditedComments:      true,
+			UseConfiguredMergeMethod: true,
+			IdentityFile: &repoFile{
+				Owner:  "openeuler",
+				Repo:   "community",
+				Branch: "master",
+				Path:   "sig/Kernel/sig-info.yaml",
+			},
+			SyncBot: "openeuler-sync-bot",
+		},
+	}
+}
+
+// getIdentities returns the name and email address of maintainers and committers in the identity file.
+func (m *mergeHelper) getIdentities(f *repoFile) map[string]commitUser {
+	r := map[string]commitUser{}
+
+	content, err := m.cache.getFileContent(f.Owner, f.Repo, f.Branch, f.Path)
+	if err != nil {
+		return r
+	}
+
+	c, err := base64.StdEncoding.DecodeString(content)
+	if err != nil {
+		return r
+	}
+
+	var s SigInfos
+	if err = yaml.Unmarshal(c, &s); err != nil {
+		return r
+	}
+
+	for _, ms := range s.Maintainers {
+		r[ms.GiteeID] = commitUser{Login: ms.GiteeID, Name: ms.Name, Email: ms.Email}
+	}
+
+	for _, i := range s.Repositories {
+		for _, j := range i.Committers {
+			r[j.GiteeID] = commitUser{Login: j.GiteeID, Name: j.Name, Email: j.Email}
+		}
+	}
+
+	return r
+}
+
+// genSyncBotBody rewrites the body of PR created by sync bot with the original PR
+// and the description of the related PR.
+func (m *mergeHelper) genSyncBotBody() string {
+	body := m.pr.GetBody()
+
+	bodySlice := strings.Split(body, "\n")
+	if len(bodySlice) < 3 {
+		return body
+	}
+
+	v := strings.Split(strings.Replace(bodySlice[1], "### ", "", -1), "1. ")
+	if len(v) < 2 {
+		return body
+	}
+	originPR := v[1]
+
+	syncRelatedPR := bodySlice[2]
+	items := strings.Split(syncRelatedPR, "/")
+	if len(items) < 7 {
+		return body
+	}
+
+	relatedPRNumber, _ := strconv.Atoi(strings.Replace(items[6], "\r", "", -1))
+	relatedDesc := ""
+	if relatedPR, err := m.cli.GetSinglePR(items[3], items[4], relatedPRNumber); err == nil {
+		relatedDesc = relatedPR.GetBody()
+	}
+
+	return fmt.Sprintf("\n%s \n%s \n \n%s", originPR, syncRelatedPR, relatedDesc)
+}